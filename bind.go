@@ -0,0 +1,324 @@
+package lars
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	formTag       = "form"
+	queryTag      = "query"
+	timeFormatTag = "time_format"
+	ignoreField   = "-"
+
+	defaultMemory = 32 << 20 // 32 MB
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	errBindTarget = errors.New("lars => binding element must be a non nil pointer to a struct")
+)
+
+// Bind decodes the request body into v, choosing the decoder based on the
+// request's Content-Type header; form bodies are bound using BindForm and
+// others using the Codec registered for the media type, see RegisterCodec.
+// Requests without a body, such as GET requests, are bound from the query
+// string instead. Errors returned are of type *HTTPError and may be returned
+// directly from a handler.
+func (c *Context) Bind(v interface{}) error {
+	req := c.Request
+
+	if req.ContentLength == 0 && (req.Method == GET || req.Method == DELETE || req.Method == HEAD) {
+		return c.BindQuery(v)
+	}
+
+	ct, _, _ := mime.ParseMediaType(req.Header.Get(ContentType))
+
+	switch ct {
+	case ApplicationForm, MultipartForm:
+		return c.BindForm(v)
+	default:
+		return c.decode(ct, v)
+	}
+}
+
+// BindJSON decodes the request body as JSON into v.
+func (c *Context) BindJSON(v interface{}) error {
+	return c.decode(ApplicationJSON, v)
+}
+
+// BindXML decodes the request body as XML into v.
+func (c *Context) BindXML(v interface{}) error {
+	return c.decode(ApplicationXML, v)
+}
+
+// BindProtobuf decodes the request body as a protobuf message into v, using
+// the Codec registered for application/protobuf.
+func (c *Context) BindProtobuf(v interface{}) error {
+	return c.decode(ApplicationProtobuf, v)
+}
+
+// BindMsgpack decodes the request body as MessagePack into v, using the Codec
+// registered for application/msgpack.
+func (c *Context) BindMsgpack(v interface{}) error {
+	return c.decode(ApplicationMsgpack, v)
+}
+
+// decode decodes the request body into v using the Codec registered for the
+// media type.
+func (c *Context) decode(mediaType string, v interface{}) error {
+	codec, ok := c.Response.lars.router.codec(mediaType)
+	if !ok {
+		return NewHTTPError(http.StatusUnsupportedMediaType)
+	}
+
+	if err := codec.Decode(c.Request.Body, v); err != nil {
+		return NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	return nil
+}
+
+// BindForm parses a url encoded or multipart form body and maps its values
+// onto the fields of the struct pointed to by v using the "form" struct tag,
+// falling back to the field name when no tag exists.
+func (c *Context) BindForm(v interface{}) error {
+	var err error
+
+	if strings.HasPrefix(c.Request.Header.Get(ContentType), MultipartForm) {
+		err = c.Request.ParseMultipartForm(defaultMemory)
+	} else {
+		err = c.Request.ParseForm()
+	}
+
+	if err != nil {
+		return NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	return bindValues(v, c.Request.PostForm, formTag)
+}
+
+// BindQuery maps the query string values onto the fields of the struct
+// pointed to by v using the "query" struct tag, falling back to the field
+// name when no tag exists.
+func (c *Context) BindQuery(v interface{}) error {
+	return bindValues(v, c.Request.URL.Query(), queryTag)
+}
+
+// bindValues maps values onto the struct pointed to by ptr.
+func bindValues(ptr interface{}, values url.Values, tag string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return NewHTTPError(http.StatusInternalServerError).SetInternal(errBindTarget)
+	}
+
+	if err := bindStruct(v.Elem(), values, tag, ""); err != nil {
+		return NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	return nil
+}
+
+func bindStruct(v reflect.Value, values url.Values, tag, namespace string) error {
+	typ := v.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		fv := v.Field(i)
+
+		// unexported
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name := sf.Tag.Get(tag)
+		if name == ignoreField {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		// embedded structs are flattened into the parent's namespace
+		if sf.Anonymous && sf.Tag.Get(tag) == "" && indirectType(sf.Type).Kind() == reflect.Struct {
+			if err := bindNested(fv, values, tag, namespace); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := namespace + name
+
+		if isNestedStruct(sf.Type) {
+			if !hasPrefix(values, key+".") {
+				continue
+			}
+			if err := bindNested(fv, values, tag, key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		vals, ok := values[key]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if err := setField(fv, vals, sf.Tag.Get(timeFormatTag)); err != nil {
+			return fmt.Errorf("field '%s': %s", key, err)
+		}
+	}
+
+	return nil
+}
+
+func bindNested(fv reflect.Value, values url.Values, tag, namespace string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	return bindStruct(fv, values, tag, namespace)
+}
+
+func setField(fv reflect.Value, vals []string, timeFormat string) error {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setField(fv.Elem(), vals, timeFormat)
+
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 && !implementsTextUnmarshaler(fv) {
+			fv.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		s := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setField(s.Index(i), []string{val}, timeFormat); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+
+	return setValue(fv, vals[0], timeFormat)
+}
+
+func setValue(fv reflect.Value, val string, timeFormat string) error {
+	if fv.Type() == timeType {
+		if val == "" {
+			return nil
+		}
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+		t, err := time.Parse(timeFormat, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if implementsTextUnmarshaler(fv) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+
+	case reflect.Bool:
+		if val == "" {
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		if val == "" {
+			val = "0"
+		}
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			val = "0"
+		}
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			val = "0"
+		}
+		n, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
+
+func implementsTextUnmarshaler(fv reflect.Value) bool {
+	return fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	t = indirectType(t)
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func hasPrefix(values url.Values, prefix string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package lars
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+type bindAddress struct {
+	Street string `form:"street" query:"street"`
+	City   string `form:"city" query:"city"`
+}
+
+type bindBase struct {
+	ID uint64 `json:"id" xml:"id" form:"id" query:"id"`
+}
+
+type bindUser struct {
+	bindBase
+	Name     string       `json:"name" xml:"name" form:"name" query:"name"`
+	Age      *int         `json:"age" xml:"age" form:"age" query:"age"`
+	Admin    bool         `json:"admin" xml:"admin" form:"admin" query:"admin"`
+	Tags     []string     `json:"tags" xml:"tags" form:"tags" query:"tags"`
+	Scores   []float64    `form:"scores" query:"scores"`
+	Born     time.Time    `form:"born" query:"born" time_format:"2006-01-02"`
+	Seen     *time.Time   `form:"seen" query:"seen"`
+	Address  bindAddress  `form:"address" query:"address"`
+	Previous *bindAddress `form:"previous" query:"previous"`
+	Ignored  string       `form:"-" query:"-"`
+	Untagged string
+}
+
+func TestBindJSON(t *testing.T) {
+	c := newTestContext(POST, "/", ApplicationJSONCharsetUTF8, `{"id":1,"name":"joeybloggs","age":32,"tags":["a","b"]}`)
+
+	var u bindUser
+	Equal(t, c.Bind(&u), nil)
+	Equal(t, u.ID, uint64(1))
+	Equal(t, u.Name, "joeybloggs")
+	Equal(t, *u.Age, 32)
+	Equal(t, u.Tags, []string{"a", "b"})
+
+	c = newTestContext(POST, "/", ApplicationJSON, `{"id":`)
+	err := c.Bind(&u)
+	NotEqual(t, err, nil)
+	Equal(t, err.(*HTTPError).Code, http.StatusBadRequest)
+}
+
+func TestBindXML(t *testing.T) {
+	c := newTestContext(POST, "/", ApplicationXMLCharsetUTF8, `<user><id>2</id><name>joeybloggs</name><admin>true</admin></user>`)
+
+	var u bindUser
+	Equal(t, c.Bind(&u), nil)
+	Equal(t, u.ID, uint64(2))
+	Equal(t, u.Name, "joeybloggs")
+	Equal(t, u.Admin, true)
+
+	c = newTestContext(POST, "/", TextXML, `<user>`)
+	err := c.Bind(&u)
+	NotEqual(t, err, nil)
+	Equal(t, err.(*HTTPError).Code, http.StatusBadRequest)
+}
+
+func TestBindForm(t *testing.T) {
+	body := "id=3&name=joeybloggs&age=25&admin=true&tags=a&tags=b&scores=1.5&scores=2" +
+		"&born=1990-05-01&seen=2016-01-02T15:04:05Z&address.street=main&address.city=ottawa" +
+		"&previous.city=toronto&Ignored=x&Untagged=y"
+
+	c := newTestContext(POST, "/", ApplicationForm, body)
+
+	var u bindUser
+	Equal(t, c.Bind(&u), nil)
+	Equal(t, u.ID, uint64(3))
+	Equal(t, u.Name, "joeybloggs")
+	Equal(t, *u.Age, 25)
+	Equal(t, u.Admin, true)
+	Equal(t, u.Tags, []string{"a", "b"})
+	Equal(t, u.Scores, []float64{1.5, 2})
+	Equal(t, u.Born, time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC))
+	Equal(t, u.Seen.Equal(time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)), true)
+	Equal(t, u.Address, bindAddress{Street: "main", City: "ottawa"})
+	Equal(t, *u.Previous, bindAddress{City: "toronto"})
+	Equal(t, u.Ignored, "")
+	Equal(t, u.Untagged, "y")
+
+	c = newTestContext(POST, "/", ApplicationForm, "age=abc")
+	err := c.Bind(&u)
+	NotEqual(t, err, nil)
+	Equal(t, err.(*HTTPError).Code, http.StatusBadRequest)
+
+	c = newTestContext(POST, "/", ApplicationForm, "name=joeybloggs")
+	NotEqual(t, c.BindForm(u), nil)
+}
+
+func TestBindMultipartForm(t *testing.T) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("name", "joeybloggs")
+	mw.WriteField("tags", "a")
+	mw.WriteField("tags", "b")
+	mw.Close()
+
+	c := newTestContext(POST, "/", mw.FormDataContentType(), buf.String())
+
+	var u bindUser
+	Equal(t, c.Bind(&u), nil)
+	Equal(t, u.Name, "joeybloggs")
+	Equal(t, u.Tags, []string{"a", "b"})
+}
+
+func TestBindQuery(t *testing.T) {
+	c := newTestContext(GET, "/?id=4&name=joeybloggs&address.city=ottawa", "", "")
+
+	var u bindUser
+	Equal(t, c.Bind(&u), nil)
+	Equal(t, u.ID, uint64(4))
+	Equal(t, u.Name, "joeybloggs")
+	Equal(t, u.Address.City, "ottawa")
+	Equal(t, u.Previous, nil)
+
+	// binding into anything but a struct pointer is a server error
+	err := c.BindQuery(u)
+	Equal(t, err.(*HTTPError).Code, http.StatusInternalServerError)
+	Equal(t, err.(*HTTPError).Internal, errBindTarget)
+}
+
+func TestBindUnsupportedMediaType(t *testing.T) {
+	c := newTestContext(POST, "/", "application/octet-stream", "data")
+
+	var u bindUser
+	err := c.Bind(&u)
+	NotEqual(t, err, nil)
+	Equal(t, err.(*HTTPError).Code, http.StatusUnsupportedMediaType)
+}
@@ -0,0 +1,192 @@
+package lars
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// chain holds a route group's middleware along with how it relates to the
+// middleware of its parent group. The middleware applied to each route is
+// computed from it when the route is registered, and again whenever a group's
+// middleware changes, rather than on every request. Its fields are only
+// modified holding the router's mu, requests use the compiled copy.
+type chain struct {
+	parent  *chain
+	inherit bool
+
+	// middleware run before and after the parent's, along with their names
+	before      []MiddlewareFunc
+	after       []MiddlewareFunc
+	beforeNames []string
+	afterNames  []string
+
+	// handlers registered using RouteGroup.NotFound and MethodNotAllowed,
+	// used in place of the router's defaults.
+	notFoundHandler         HandlerFunc
+	methodNotAllowedHandler HandlerFunc
+
+	// compiled holds the *compiledChain used to serve requests.
+	compiled atomic.Value
+}
+
+// compiledChain is a chain's middleware, along with the handlers used when a
+// request reaches the group but doesn't match one of its routes, precompiled
+// for serving requests. A new one is published each time the chain changes so
+// that requests never observe one partially built.
+type compiledChain struct {
+	middleware       []MiddlewareFunc
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	automaticOptions HandlerFunc
+}
+
+// newChain returns a new chain for a group, registering it with the router so
+// that it's recompiled when middleware changes.
+func (r *router) newChain(parent *chain, inherit bool) *chain {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &chain{
+		parent:  parent,
+		inherit: inherit,
+	}
+
+	r.chains = append(r.chains, c)
+	c.compile(r.lars)
+
+	return c
+}
+
+// middleware returns the full list of middleware, in the order they run.
+func (c *chain) middleware() []MiddlewareFunc {
+	mw := append([]MiddlewareFunc{}, c.before...)
+
+	if c.inherit && c.parent != nil {
+		mw = append(mw, c.parent.middleware()...)
+	}
+
+	return append(mw, c.after...)
+}
+
+// names returns the names of the middleware, in the order they run.
+func (c *chain) names() []string {
+	names := append([]string{}, c.beforeNames...)
+
+	if c.inherit && c.parent != nil {
+		names = append(names, c.parent.names()...)
+	}
+
+	return append(names, c.afterNames...)
+}
+
+// then returns the handler wrapped by the chain's middleware.
+func (c *chain) then(h HandlerFunc) HandlerFunc {
+	return wrap(c.middleware(), h)
+}
+
+// handlers returns the compiled chain used to serve requests.
+func (c *chain) handlers() *compiledChain {
+	return c.compiled.Load().(*compiledChain)
+}
+
+// then returns the handler wrapped by the compiled middleware.
+func (cc *compiledChain) then(h HandlerFunc) HandlerFunc {
+	return wrap(cc.middleware, h)
+}
+
+// wrap returns the handler wrapped by mw, the first running first.
+func wrap(mw []MiddlewareFunc, h HandlerFunc) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+
+	return h
+}
+
+// compile publishes the chain's middleware and the handlers for unmatched
+// requests wrapped by it.
+func (c *chain) compile(l *LARS) {
+	notFound, notAllowed := c.notFoundHandler, c.methodNotAllowedHandler
+
+	if notFound == nil {
+		notFound = l.http404
+	}
+
+	if notAllowed == nil {
+		notAllowed = methodNotAllowedHandler
+	}
+
+	mw := c.middleware()
+
+	c.compiled.Store(&compiledChain{
+		middleware:       mw,
+		notFound:         wrap(mw, notFound),
+		methodNotAllowed: wrap(mw, notAllowed),
+		automaticOptions: wrap(mw, automaticOptionsHandler),
+	})
+}
+
+// rebuild runs fn, which modifies a chain's middleware or the handlers it
+// uses, and then recompiles the handlers of every chain and registered route.
+func (r *router) rebuild(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn()
+
+	for _, c := range r.chains {
+		c.compile(r.lars)
+	}
+
+	r.update(r.routers(), func() {
+		for _, rt := range r.routes {
+			h := rt.group.chain.then(rt.handler)
+			for _, mh := range rt.mhs {
+				mh.setHandler(rt.Method, h)
+			}
+		}
+	})
+}
+
+// addGroup returns a copy of groups, which is ordered by prefix length with
+// the longest first, with the group added unless it's already present.
+func addGroup(groups []*RouteGroup, g *RouteGroup) []*RouteGroup {
+	for _, other := range groups {
+		if other.chain == g.chain {
+			return groups
+		}
+	}
+
+	i := 0
+	for ; i < len(groups) && len(groups[i].prefix) > len(g.prefix); i++ {
+	}
+
+	// a new slice is used as the current one may be in use serving requests
+	ng := make([]*RouteGroup, 0, len(groups)+1)
+	ng = append(ng, groups[:i]...)
+	ng = append(ng, g)
+
+	return append(ng, groups[i:]...)
+}
+
+// matchGroup returns the group with the longest prefix matching path, or def
+// when none of groups match.
+func matchGroup(groups []*RouteGroup, path string, def *RouteGroup) *RouteGroup {
+	for _, g := range groups {
+		if hasPathPrefix(path, g.prefix) {
+			return g
+		}
+	}
+
+	return def
+}
+
+// hasPathPrefix reports whether path begins with prefix on a segment boundary,
+// so that "/api" matches "/api/users" but not "/apiary".
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || prefix == "" || prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/'
+}
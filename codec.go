@@ -0,0 +1,203 @@
+package lars
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+)
+
+// Codec encodes response bodies and decodes request bodies of a media type.
+// Codecs are registered on LARS using RegisterCodec and used by
+// Context.Encode, Negotiate and Bind, along with the methods specific to the
+// built in media types, such as Context.JSON and BindJSON.
+type Codec interface {
+	// ContentType returns the Content-Type header sent with encoded values,
+	// eg. application/json; charset=utf-8
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// ProtoMarshaler is implemented by generated protobuf messages able to
+// marshal themselves, such as those generated by gogo/protobuf or vtprotobuf.
+type ProtoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// ProtoUnmarshaler is implemented by generated protobuf messages able to
+// unmarshal themselves.
+type ProtoUnmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// MsgpackMarshaler is implemented by types able to marshal themselves as
+// MessagePack, such as those generated by tinylib/msgp.
+type MsgpackMarshaler interface {
+	MarshalMsg([]byte) ([]byte, error)
+}
+
+// MsgpackUnmarshaler is implemented by types able to unmarshal themselves
+// from MessagePack, returning any remaining bytes.
+type MsgpackUnmarshaler interface {
+	UnmarshalMsg([]byte) ([]byte, error)
+}
+
+// JSONCodec is the Codec registered for application/json.
+type JSONCodec struct{}
+
+// ContentType returns application/json; charset=utf-8
+func (JSONCodec) ContentType() string { return ApplicationJSONCharsetUTF8 }
+
+// Encode writes v as JSON.
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Decode reads JSON into v.
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec is the Codec registered for application/xml and text/xml.
+type XMLCodec struct {
+	// MediaType is that of the Content-Type sent, application/xml when empty.
+	MediaType string
+}
+
+// ContentType returns the media type with the utf-8 charset.
+func (x XMLCodec) ContentType() string {
+	if x.MediaType == "" {
+		return ApplicationXMLCharsetUTF8
+	}
+	return x.MediaType + "; " + CharsetUTF8
+}
+
+// Encode writes v as XML, prepending the standard XML header.
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode reads XML into v.
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// ProtobufCodec is the Codec registered for application/protobuf. It
+// requires values to implement ProtoMarshaler and ProtoUnmarshaler; register
+// a Codec using the protobuf library of your choice to support other
+// messages, eg. those generated by google.golang.org/protobuf.
+type ProtobufCodec struct{}
+
+// ContentType returns application/protobuf
+func (ProtobufCodec) ContentType() string { return ApplicationProtobuf }
+
+// Encode writes the protobuf encoding of v.
+func (ProtobufCodec) Encode(w io.Writer, v interface{}) error {
+	m, ok := v.(ProtoMarshaler)
+	if !ok {
+		return fmt.Errorf("lars => %T does not implement ProtoMarshaler", v)
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode reads the protobuf encoding of v.
+func (ProtobufCodec) Decode(r io.Reader, v interface{}) error {
+	m, ok := v.(ProtoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("lars => %T does not implement ProtoUnmarshaler", v)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return m.Unmarshal(b)
+}
+
+// MsgpackCodec is the Codec registered for application/msgpack. It requires
+// values to implement MsgpackMarshaler and MsgpackUnmarshaler; register a
+// Codec using the MessagePack library of your choice to support other types.
+type MsgpackCodec struct{}
+
+// ContentType returns application/msgpack
+func (MsgpackCodec) ContentType() string { return ApplicationMsgpack }
+
+// Encode writes the MessagePack encoding of v.
+func (MsgpackCodec) Encode(w io.Writer, v interface{}) error {
+	m, ok := v.(MsgpackMarshaler)
+	if !ok {
+		return fmt.Errorf("lars => %T does not implement MsgpackMarshaler", v)
+	}
+
+	b, err := m.MarshalMsg(nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode reads the MessagePack encoding of v.
+func (MsgpackCodec) Decode(r io.Reader, v interface{}) error {
+	m, ok := v.(MsgpackUnmarshaler)
+	if !ok {
+		return fmt.Errorf("lars => %T does not implement MsgpackUnmarshaler", v)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.UnmarshalMsg(b)
+	return err
+}
+
+// codecs holds the registered codecs by media type.
+type codecs map[string]Codec
+
+// defaultCodecs returns the codecs registered by New.
+func defaultCodecs() codecs {
+	return codecs{
+		ApplicationJSON:     JSONCodec{},
+		ApplicationXML:      XMLCodec{},
+		TextXML:             XMLCodec{MediaType: TextXML},
+		ApplicationProtobuf: ProtobufCodec{},
+		ApplicationMsgpack:  MsgpackCodec{},
+	}
+}
+
+// codec returns the registered codec for the media type, ignoring its
+// parameters and case.
+func (r *router) codec(mediaType string) (Codec, bool) {
+	cs := r.codecs.Load().(codecs)
+
+	// media types without parameters, such as the constants, are looked up
+	// directly
+	if c, ok := cs[mediaType]; ok {
+		return c, true
+	}
+
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return nil, false
+	}
+
+	c, ok := cs[mt]
+	return c, ok
+}
@@ -0,0 +1,261 @@
+package lars
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+// codecUser marshals itself as its name prefixed by a marker byte for the
+// format, standing in for generated protobuf and msgpack types.
+type codecUser struct {
+	Name string
+}
+
+func (u *codecUser) Marshal() ([]byte, error) {
+	if u.Name == "" {
+		return nil, errors.New("empty name")
+	}
+	return append([]byte{0x0a}, u.Name...), nil
+}
+
+func (u *codecUser) Unmarshal(b []byte) error {
+	if len(b) == 0 || b[0] != 0x0a {
+		return errors.New("invalid protobuf")
+	}
+	u.Name = string(b[1:])
+	return nil
+}
+
+func (u *codecUser) MarshalMsg(b []byte) ([]byte, error) {
+	if u.Name == "" {
+		return nil, errors.New("empty name")
+	}
+	return append(append(b, 0xa0|byte(len(u.Name))), u.Name...), nil
+}
+
+func (u *codecUser) UnmarshalMsg(b []byte) ([]byte, error) {
+	if len(b) == 0 || b[0]&0xe0 != 0xa0 || len(b) < int(b[0]&0x1f)+1 {
+		return b, errors.New("invalid msgpack")
+	}
+	n := int(b[0]&0x1f) + 1
+	u.Name = string(b[1:n])
+	return b[n:], nil
+}
+
+// csvCodec is a custom codec encoding strings as a single csv line.
+type csvCodec struct{}
+
+func (csvCodec) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvCodec) Encode(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, strings.Join(v.([]string), ",")+"\n")
+	return err
+}
+
+func (csvCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*v.(*[]string) = strings.Split(strings.TrimSpace(string(b)), ",")
+	return nil
+}
+
+func TestCodecRender(t *testing.T) {
+	l := New()
+	l.RegisterCodec("Text/CSV", csvCodec{})
+
+	l.Get("/protobuf", func(c *Context) error {
+		return c.Protobuf(http.StatusOK, &codecUser{Name: "joeybloggs"})
+	})
+	l.Get("/msgpack", func(c *Context) error {
+		return c.Msgpack(http.StatusCreated, &codecUser{Name: "joeybloggs"})
+	})
+	l.Get("/csv", func(c *Context) error {
+		return c.Encode(http.StatusOK, "text/csv", []string{"a", "b"})
+	})
+	l.Get("/badprotobuf", func(c *Context) error {
+		return c.Protobuf(http.StatusOK, &codecUser{})
+	})
+	l.Get("/unregistered", func(c *Context) error {
+		return c.Encode(http.StatusOK, "application/cbor", renderUser{})
+	})
+	l.Get("/negotiate", func(c *Context) error {
+		u := &codecUser{Name: "joeybloggs"}
+		return c.Negotiate(http.StatusOK, []Offer{
+			{ApplicationJSON, u},
+			{ApplicationMsgpack, u},
+			{ApplicationProtobuf, u},
+			{TextXML, renderUser{ID: 1}},
+		})
+	})
+
+	tests := []struct {
+		path        string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/protobuf", "", http.StatusOK, ApplicationProtobuf, "\x0ajoeybloggs"},
+		{"/msgpack", "", http.StatusCreated, ApplicationMsgpack, "\xaajoeybloggs"},
+		{"/csv", "", http.StatusOK, "text/csv; charset=utf-8", "a,b\n"},
+		{"/badprotobuf", "", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+		{"/unregistered", "", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+		{"/negotiate", "", http.StatusOK, ApplicationJSONCharsetUTF8, "{\"Name\":\"joeybloggs\"}\n"},
+		{"/negotiate", "application/msgpack", http.StatusOK, ApplicationMsgpack, "\xaajoeybloggs"},
+		{"/negotiate", "application/json;q=0.5, application/protobuf", http.StatusOK, ApplicationProtobuf, "\x0ajoeybloggs"},
+		{"/negotiate", "text/xml", http.StatusOK, TextXMLCharsetUTF8, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<renderUser><id>1</id><name></name></renderUser>"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(GET, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set(Accept, tt.accept)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, tt.code)
+		Equal(t, w.Header().Get(ContentType), tt.contentType)
+		Equal(t, w.Body.String(), tt.body)
+	}
+}
+
+func TestCodecUnsupportedType(t *testing.T) {
+	l := New()
+
+	var err error
+
+	l.Get("/msgpack", func(c *Context) error {
+		err = c.Msgpack(http.StatusOK, map[string]int{"id": 1})
+		return err
+	})
+	l.Post("/protobuf", func(c *Context) error {
+		err = c.BindProtobuf(&renderUser{})
+		return err
+	})
+
+	code, _ := request(GET, "/msgpack", l)
+	Equal(t, code, http.StatusInternalServerError)
+	Equal(t, err.Error(), "lars => map[string]int does not implement MsgpackMarshaler")
+
+	r, _ := http.NewRequest(POST, "/protobuf", strings.NewReader("\x0a"))
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusBadRequest)
+	Equal(t, err.(*HTTPError).Internal.Error(), "lars => *lars.renderUser does not implement ProtoUnmarshaler")
+}
+
+func TestCodecOverride(t *testing.T) {
+	l := New()
+	l.RegisterCodec(ApplicationJSON, csvCodec{})
+
+	l.Get("/", func(c *Context) error {
+		return c.JSON(http.StatusOK, []string{"a", "b"})
+	})
+
+	code, body := request(GET, "/", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "a,b\n")
+
+	PanicMatches(t, func() { l.RegisterCodec("", csvCodec{}) }, "lars => invalid media type ''")
+	PanicMatches(t, func() { l.RegisterCodec("text/csv", nil) }, "lars => nil codec for media type 'text/csv'")
+}
+
+func TestCodecRegisterWhileServing(t *testing.T) {
+	l := New()
+	l.Get("/", func(c *Context) error {
+		return c.JSON(http.StatusOK, 1)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.RegisterCodec(fmt.Sprintf("application/x-%d", i), csvCodec{})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		code, body := request(GET, "/", l)
+		Equal(t, code, http.StatusOK)
+		Equal(t, body, "1\n")
+	}
+
+	<-done
+}
+
+func TestBindCodecs(t *testing.T) {
+	l := New()
+	l.RegisterCodec("text/csv", csvCodec{})
+
+	l.Post("/", func(c *Context) error {
+		var u codecUser
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, u.Name)
+	})
+	l.Post("/protobuf", func(c *Context) error {
+		var u codecUser
+		if err := c.BindProtobuf(&u); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, u.Name)
+	})
+	l.Post("/msgpack", func(c *Context) error {
+		var u codecUser
+		if err := c.BindMsgpack(&u); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, u.Name)
+	})
+	l.Post("/csv", func(c *Context) error {
+		var s []string
+		if err := c.Bind(&s); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, strings.Join(s, "|"))
+	})
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		code        int
+		expected    string
+	}{
+		{"/", ApplicationProtobuf, "\x0ajoeybloggs", http.StatusOK, "joeybloggs"},
+		{"/", ApplicationMsgpack, "\xa4joey", http.StatusOK, "joey"},
+		{"/", ApplicationProtobuf, "bad", http.StatusBadRequest, "invalid protobuf\n"},
+		{"/", "application/cbor", "bad", http.StatusUnsupportedMediaType, "Unsupported Media Type\n"},
+		{"/protobuf", "", "\x0ajoeybloggs", http.StatusOK, "joeybloggs"},
+		{"/msgpack", "", "\xa6bloggs", http.StatusOK, "bloggs"},
+		{"/csv", "text/csv; charset=utf-8", "a,b", http.StatusOK, "a|b"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(POST, tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set(ContentType, tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, tt.code)
+		Equal(t, w.Body.String(), tt.expected)
+	}
+
+	var buf bytes.Buffer
+	Equal(t, XMLCodec{}.Encode(&buf, renderUser{ID: 1}), nil)
+	Equal(t, buf.String(), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<renderUser><id>1</id><name></name></renderUser>")
+}
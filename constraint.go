@@ -0,0 +1,139 @@
+package lars
+
+import (
+	"regexp"
+	"strings"
+)
+
+// paramConstraint restricts the values a path parameter matches, it is
+// declared after the parameter name; eg. /users/:id<int>
+type paramConstraint struct {
+	pattern string
+	fn      func(string) bool
+}
+
+// typedConstraints are the named constraints available in addition to
+// regular expressions.
+var typedConstraints = map[string]func(string) bool{
+	"int":   isInt,
+	"uint":  isUint,
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"uuid":  isUUID,
+}
+
+// newParamConstraint compiles the pattern, which is either the name of a
+// typed constraint or a regular expression that must match the whole value.
+func newParamConstraint(pattern string) *paramConstraint {
+	if pattern == "" {
+		panic("lars => empty parameter constraint")
+	}
+
+	if fn, ok := typedConstraints[pattern]; ok {
+		return &paramConstraint{pattern: pattern, fn: fn}
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		panic("lars => invalid parameter constraint '" + pattern + "': " + err.Error())
+	}
+
+	return &paramConstraint{pattern: pattern, fn: re.MatchString}
+}
+
+// match reports whether the value satisfies the constraint, a nil constraint
+// matches everything.
+func (pc *paramConstraint) match(value string) bool {
+	return pc == nil || pc.fn(value)
+}
+
+// String returns the constraint's pattern.
+func (pc *paramConstraint) String() string {
+	if pc == nil {
+		return ""
+	}
+	return pc.pattern
+}
+
+// constraintEnd returns the index of the '>' closing the constraint opened at
+// index i, nested '<' '>' pairs are permitted within regular expressions.
+func constraintEnd(path string, i int) int {
+	depth := 0
+	for ; i < len(path); i++ {
+		switch path[i] {
+		case '<':
+			depth++
+		case '>':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	panic("lars => unterminated parameter constraint in '" + path + "'")
+}
+
+func isInt(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	return isUint(s)
+}
+
+func isUint(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c|0x20 < 'a' || c|0x20 > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if c := s[i]; c >= '0' && c <= '9' {
+				continue
+			}
+			// only letters are case folded, otherwise 0x10-0x19 would pass as digits
+			if c := s[i] | 0x20; c < 'a' || c > 'f' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -2,6 +2,8 @@ package lars
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"golang.org/x/net/context"
@@ -16,7 +18,16 @@ type Context struct {
 	path     string
 	pnames   []string
 	pvalues  []string
+	hnames   []string
+	hvalues  []string
 	store    store
+	query    url.Values
+	allow    string
+	head     bool
+	escaped  bool
+	err      error
+	parent   context.Context
+	cancel   context.CancelFunc
 }
 
 type store map[string]interface{}
@@ -40,28 +51,77 @@ func (c *Context) Path() string {
 func (c *Context) P(i int) (value string) {
 	l := len(c.pnames)
 	if i < l {
-		value = c.pvalues[i]
+		value = c.unescape(c.pvalues[i])
 	}
 	return
 }
 
-// Param returns path parameter by name.
-func (c *Context) Param(name string) (value string) {
+// Param returns path parameter by name, falling back to the parameters
+// captured from the host for routes registered using LARS.Host. When routing
+// on the escaped path the value is decoded.
+func (c *Context) Param(name string) string {
+	return c.unescape(c.RawParam(name))
+}
+
+// RawParam returns path parameter by name as it appeared in the escaped path
+// when LARS.UseEscapedPath is enabled, eg. "a%2Fb"; otherwise it's identical
+// to Param.
+func (c *Context) RawParam(name string) (value string) {
 	l := len(c.pnames)
 	for i, n := range c.pnames {
 		if n == name && i < l {
-			value = c.pvalues[i]
-			break
+			return c.pvalues[i]
+		}
+	}
+	for i, n := range c.hnames {
+		if n == name {
+			return c.hvalues[i]
 		}
 	}
 	return
 }
 
+// setParam stores the value of the parameter at index i, growing pvalues for
+// routes registered after the Context was allocated.
+func (c *Context) setParam(i int, value string) {
+	if i >= len(c.pvalues) {
+		pvalues := make([]string, i+1)
+		copy(pvalues, c.pvalues)
+		c.pvalues = pvalues
+	}
+	c.pvalues[i] = value
+}
+
+// unescape decodes a parameter value captured from the escaped path, the
+// value is returned as is when it can't be decoded.
+func (c *Context) unescape(value string) string {
+	if !c.escaped || strings.IndexByte(value, '%') == -1 {
+		return value
+	}
+	if v, err := url.PathUnescape(value); err == nil {
+		return v
+	}
+	return value
+}
+
 // Params returns contexts parameters
 func (c *Context) Params() []string {
 	return c.pnames
 }
 
+// HandlerError returns the error returned by the handler, or by middleware
+// earlier in the chain, if any.
+func (c *Context) HandlerError() error {
+	return c.err
+}
+
+// SetHandlerError sets the error that will be passed to the registered error
+// handler once the chain completes; middleware may use it to replace or
+// clear, by passing nil, the error returned by the handler.
+func (c *Context) SetHandlerError(err error) {
+	c.err = err
+}
+
 // Get retrieves data from the context.
 func (c *Context) Get(key string) interface{} {
 	return c.store[key]
@@ -75,6 +135,53 @@ func (c *Context) Set(key string, val interface{}) {
 	c.store[key] = val
 }
 
+// WithCancel replaces the Context's parent with a cancelable copy of it and
+// returns the function that cancels it. Done is closed when the returned
+// cancel function is called, the client goes away or the parent is canceled,
+// whichever happens first. Calling the cancel function also restores the
+// previous parent, so middleware and handlers running after it are
+// unaffected.
+func (c *Context) WithCancel() context.CancelFunc {
+	return c.derive(context.WithCancel(c.netContext()))
+}
+
+// WithDeadline replaces the Context's parent with a copy whose deadline is
+// adjusted to be no later than d and returns the function that cancels it,
+// restoring the previous parent.
+func (c *Context) WithDeadline(d time.Time) context.CancelFunc {
+	return c.derive(context.WithDeadline(c.netContext(), d))
+}
+
+// WithTimeout is shorthand for WithDeadline(time.Now().Add(timeout)); it can
+// be used within middleware to apply a timeout to a single route or group.
+func (c *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	return c.derive(context.WithTimeout(c.netContext(), timeout))
+}
+
+// derive makes ctx the Context's parent until the returned function, which
+// cancels it, is called; the previous parent is then restored unless another
+// has since been derived and not yet canceled.
+func (c *Context) derive(ctx context.Context, cancel context.CancelFunc) context.CancelFunc {
+	prev := c.parent
+	c.parent = ctx
+
+	return func() {
+		cancel()
+		if c.parent == ctx {
+			c.parent = prev
+		}
+	}
+}
+
+// netContext returns the parent context, falling back to the background
+// context when the Context has not been initialized with a request.
+func (c *Context) netContext() context.Context {
+	if c.parent == nil {
+		return context.Background()
+	}
+	return c.parent
+}
+
 /************************************/
 /***** GOLANG.ORG/X/NET/CONTEXT *****/
 /************************************/
@@ -83,7 +190,10 @@ func (c *Context) Set(key string, val interface{}) {
 // should be canceled.  Deadline returns ok==false when no deadline is
 // set.  Successive calls to Deadline return the same results.
 func (c *Context) Deadline() (deadline time.Time, ok bool) {
-	return
+	if c.parent == nil {
+		return
+	}
+	return c.parent.Deadline()
 }
 
 // Done returns a channel that's closed when work done on behalf of this
@@ -116,7 +226,10 @@ func (c *Context) Deadline() (deadline time.Time, ok bool) {
 // See http://blog.golang.org/pipelines for more examples of how to use
 // a Done channel for cancelation.
 func (c *Context) Done() <-chan struct{} {
-	return nil
+	if c.parent == nil {
+		return nil
+	}
+	return c.parent.Done()
 }
 
 // Err returns a non-nil error value after Done is closed.  Err returns
@@ -124,7 +237,10 @@ func (c *Context) Done() <-chan struct{} {
 // context's deadline passed.  No other values for Err are defined.
 // After Done is closed, successive calls to Err return the same value.
 func (c *Context) Err() error {
-	return nil
+	if c.parent == nil {
+		return nil
+	}
+	return c.parent.Err()
 }
 
 // Value returns the value associated with this context for key, or nil
@@ -177,17 +293,45 @@ func (c *Context) Value(key interface{}) interface{} {
 		return c.Request
 	}
 	if keyAsString, ok := key.(string); ok {
-		return c.Get(keyAsString)
+		if v, ok := c.store[keyAsString]; ok {
+			return v
+		}
+	}
+	if c.parent == nil {
+		return nil
 	}
-	return nil
+	return c.parent.Value(key)
 }
 
-func (c *Context) reset(r *http.Request, w http.ResponseWriter, e *LARS) {
+func (c *Context) reset(r *http.Request, w http.ResponseWriter, l *LARS) {
 	c.Request = r
-	c.Response.reset(w, e)
+	c.Response.reset(w, l)
+	c.Response.discardBody = c.head
+	c.escaped = l.UseEscapedPath
 	c.store = nil
+	c.query = nil
+	c.err = nil
+
+	// the request's context is canceled by net/http when the client's
+	// connection closes or ServeHTTP returns, so it's used as is unless a
+	// timeout applies; deriving from it would allocate on every request.
+	if t := l.Timeout; t > 0 {
+		c.parent, c.cancel = context.WithTimeout(r.Context(), t)
+	} else {
+		c.parent, c.cancel = r.Context(), nil
+	}
 
 	if c.Globals != nil {
 		c.Globals.Reset()
 	}
 }
+
+// release cancels the request scoped context once the handler chain has
+// returned so that any resources held by it are freed before pooling.
+func (c *Context) release() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.parent = nil
+	c.cancel = nil
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
 	. "gopkg.in/go-playground/assert.v1"
 )
 
@@ -106,3 +108,137 @@ func TestContextNetContext(t *testing.T) {
 	c.Set("key", "val")
 	Equal(t, "val", c.Value("key"))
 }
+
+type ctxKey struct{}
+
+func TestContextCancellation(t *testing.T) {
+	l := New()
+
+	// net/http cancels the request's context once ServeHTTP returns
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+
+	get := func(path string) int {
+		res, err := http.Get(srv.URL + path)
+		Equal(t, err, nil)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	var done <-chan struct{}
+
+	l.Get("/", func(c *Context) {
+		NotEqual(t, c.Done(), nil)
+		Equal(t, c.Err(), nil)
+		done = c.Done()
+	})
+
+	code := get("/")
+	Equal(t, code, http.StatusOK)
+
+	// context is canceled once the request completes
+	<-done
+
+	// including contexts derived from it before Done was ever called
+	var (
+		derived context.Context
+		cancel  context.CancelFunc
+	)
+
+	l.Get("/derived", func(c *Context) {
+		ctx := context.WithValue(c, ctxKey{}, "value")
+		derived, cancel = context.WithTimeout(ctx, time.Hour)
+	})
+
+	code = get("/derived")
+	Equal(t, code, http.StatusOK)
+	defer cancel()
+
+	<-derived.Done()
+	Equal(t, derived.Err(), context.Canceled)
+
+	l.Get("/timeout", func(c *Context) {
+		cancel := c.WithTimeout(time.Millisecond)
+		defer cancel()
+
+		_, ok := c.Deadline()
+		Equal(t, ok, true)
+
+		<-c.Done()
+		Equal(t, c.Err(), context.DeadlineExceeded)
+	})
+
+	code, _ = request(GET, "/timeout", l)
+	Equal(t, code, http.StatusOK)
+
+	l.Get("/cancel", func(c *Context) {
+		cancel := c.WithCancel()
+		done := c.Done()
+		cancel()
+		<-done
+
+		// the previous parent is restored
+		Equal(t, c.Err(), nil)
+	})
+
+	// a timeout applied by inner middleware doesn't outlive it
+	scoped := l.Group("/scoped", func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			next(c)
+			Equal(t, c.Err(), nil)
+			_, ok := c.Deadline()
+			Equal(t, ok, false)
+		}
+	})
+	scoped.Get("", func(c *Context) {
+		cancel := c.WithTimeout(time.Hour)
+		defer cancel()
+
+		inner := c.WithDeadline(time.Now())
+		defer inner()
+
+		<-c.Done()
+		Equal(t, c.Err(), context.DeadlineExceeded)
+		inner()
+
+		_, ok := c.Deadline()
+		Equal(t, ok, true)
+		Equal(t, c.Err(), nil)
+	})
+
+	code, _ = request(GET, "/scoped", l)
+	Equal(t, code, http.StatusOK)
+
+	code, _ = request(GET, "/cancel", l)
+	Equal(t, code, http.StatusOK)
+
+	l.Timeout = time.Millisecond
+
+	l.Get("/global", func(c *Context) {
+		_, ok := c.Deadline()
+		Equal(t, ok, true)
+		<-c.Done()
+		Equal(t, c.Err(), context.DeadlineExceeded)
+	})
+
+	code, _ = request(GET, "/global", l)
+	Equal(t, code, http.StatusOK)
+}
+
+func TestContextClientDisconnect(t *testing.T) {
+	l := New()
+
+	parent, cancel := context.WithCancel(context.Background())
+
+	l.Get("/", func(c *Context) {
+		cancel()
+		<-c.Done()
+		Equal(t, c.Err(), context.Canceled)
+	})
+
+	r, _ := http.NewRequest(GET, "/", nil)
+	r = r.WithContext(parent)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusOK)
+}
@@ -0,0 +1,72 @@
+package lars
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError represents an error that occurred while handling a request and
+// carries the http status code and message to be returned to the client
+// along with the internal cause, which is never sent to the client.
+type HTTPError struct {
+	Code     int
+	Message  string
+	Internal error
+}
+
+// ErrorHandlerFunc is the function used to turn a handler's returned error
+// into an http response.
+type ErrorHandlerFunc func(*Context, error)
+
+// NewHTTPError creates a new HTTPError instance; when no message is
+// provided the http status text for the code is used.
+func NewHTTPError(code int, message ...interface{}) *HTTPError {
+	e := &HTTPError{Code: code, Message: http.StatusText(code)}
+	if len(message) > 0 {
+		e.Message = fmt.Sprint(message...)
+	}
+	return e
+}
+
+// Error returns the error message, including the internal cause if set.
+func (e *HTTPError) Error() string {
+	if e.Internal == nil {
+		return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("code=%d, message=%s, internal=%v", e.Code, e.Message, e.Internal)
+}
+
+// SetInternal sets the internal cause of the error and returns the HTTPError
+// for chaining.
+func (e *HTTPError) SetInternal(err error) *HTTPError {
+	e.Internal = err
+	return e
+}
+
+// defaultErrorHandler writes the error as JSON when the client prefers it, as
+// negotiated by Accepts, and as plain text otherwise; errors other than
+// *HTTPError are reported as 500 Internal Server Error so their details never
+// leak to the client.
+func defaultErrorHandler(c *Context, err error) {
+	if c.Response.committed {
+		return
+	}
+
+	he, ok := err.(*HTTPError)
+	if !ok {
+		he = NewHTTPError(http.StatusInternalServerError)
+	}
+
+	if c.Accepts(TextPlain, ApplicationJSON) == ApplicationJSON {
+		b, err := json.Marshal(map[string]string{"message": he.Message})
+		if err == nil {
+			c.Response.Header().Set(ContentType, ApplicationJSONCharsetUTF8)
+			c.Response.WriteHeader(he.Code)
+			c.Response.Write(b)
+			return
+		}
+	}
+
+	http.Error(c.Response, he.Message, he.Code)
+}
@@ -0,0 +1,134 @@
+package lars
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestHTTPError(t *testing.T) {
+	err := NewHTTPError(http.StatusBadRequest)
+	Equal(t, err.Message, "Bad Request")
+	Equal(t, err.Error(), "code=400, message=Bad Request")
+
+	err = NewHTTPError(http.StatusNotFound, "user ", 1, " not found")
+	Equal(t, err.Message, "user 1 not found")
+
+	err.SetInternal(errors.New("sql: no rows in result set"))
+	Equal(t, err.Error(), "code=404, message=user 1 not found, internal=sql: no rows in result set")
+}
+
+func TestErrorReturningHandler(t *testing.T) {
+	l := New()
+
+	l.Get("/ok", func(c *Context) error {
+		c.Response.Write([]byte("ok"))
+		return nil
+	})
+
+	l.Get("/http", ErrorReturningHandlerFunc(func(c *Context) error {
+		return NewHTTPError(http.StatusTeapot, "short and stout")
+	}))
+
+	l.Get("/internal", func(c *Context) error {
+		return errors.New("database is on fire")
+	})
+
+	code, body := request(GET, "/ok", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "ok")
+
+	code, body = request(GET, "/http", l)
+	Equal(t, code, http.StatusTeapot)
+	Equal(t, body, "short and stout\n")
+
+	code, body = request(GET, "/internal", l)
+	Equal(t, code, http.StatusInternalServerError)
+	Equal(t, body, "Internal Server Error\n")
+
+	// JSON
+	r, _ := http.NewRequest(GET, "/http", nil)
+	r.Header.Set(Accept, ApplicationJSON)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusTeapot)
+	Equal(t, w.Header().Get(ContentType), ApplicationJSONCharsetUTF8)
+	Equal(t, w.Body.String(), `{"message":"short and stout"}`)
+	Equal(t, w.Header().Get(Vary), Accept)
+
+	// JSON only when preferred
+	accepts := []struct {
+		accept      string
+		contentType string
+	}{
+		{"application/json;q=0, text/plain", TextPlainCharsetUTF8},
+		{"text/plain;q=0.5, application/*", ApplicationJSONCharsetUTF8},
+		{"*/*", TextPlainCharsetUTF8},
+		{"text/html", TextPlainCharsetUTF8},
+	}
+
+	for _, tt := range accepts {
+		r, _ = http.NewRequest(GET, "/http", nil)
+		r.Header.Set(Accept, tt.accept)
+		w = httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, http.StatusTeapot)
+		Equal(t, w.Header().Get(ContentType), tt.contentType)
+	}
+}
+
+func TestErrorHandlerMiddleware(t *testing.T) {
+	l := New()
+
+	// transforms errors returned further down the chain
+	l.Use(func(h HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			h(c)
+			if err := c.HandlerError(); err != nil {
+				if _, ok := err.(*HTTPError); !ok {
+					c.SetHandlerError(NewHTTPError(http.StatusBadGateway).SetInternal(err))
+				}
+			}
+		}
+	})
+
+	l.Get("/", func(c *Context) error {
+		return errors.New("upstream failed")
+	})
+
+	code, body := request(GET, "/", l)
+	Equal(t, code, http.StatusBadGateway)
+	Equal(t, body, "Bad Gateway\n")
+
+	// error returning middleware halts the chain
+	g := l.Group("/auth", func(c *Context) error {
+		return NewHTTPError(http.StatusUnauthorized)
+	})
+	g.Get("/", func(c *Context) {
+		c.Response.Write([]byte("should not reach"))
+	})
+
+	code, body = request(GET, "/auth/", l)
+	Equal(t, code, http.StatusUnauthorized)
+	Equal(t, body, "Unauthorized\n")
+
+	l2 := New()
+	l2.Use(func(c *Context) error { return nil })
+	l2.Get("/", func(c *Context) error {
+		return errors.New("custom")
+	})
+
+	var handled error
+
+	l2.RegisterErrorHandler(func(c *Context, err error) {
+		handled = err
+		c.Response.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	code, _ = request(GET, "/", l2)
+	Equal(t, code, http.StatusServiceUnavailable)
+	Equal(t, handled.Error(), "custom")
+}
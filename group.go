@@ -1,115 +1,318 @@
 package lars
 
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
 // IRouteGroup interface for router group
 type IRouteGroup interface {
 	IRoutes
 	Group(prefix string, m ...Middleware) IRouteGroup
+	GroupWithNone(prefix string, m ...Middleware) IRouteGroup
+	NotFound(Handler)
+	MethodNotAllowed(Handler)
 }
 
 // IRoutes interface for routes
 type IRoutes interface {
 	Use(...Middleware)
-	Any(string, Handler)
-	Get(string, Handler)
-	Post(string, Handler)
-	Delete(string, Handler)
-	Patch(string, Handler)
-	Put(string, Handler)
-	Options(string, Handler)
-	Head(string, Handler)
-	Connect(string, Handler)
-	Trace(string, Handler)
+	UseBefore(...Middleware)
+	Any(string, Handler) *Route
+	Get(string, Handler) *Route
+	Post(string, Handler) *Route
+	Delete(string, Handler) *Route
+	Patch(string, Handler) *Route
+	Put(string, Handler) *Route
+	Options(string, Handler) *Route
+	Head(string, Handler) *Route
+	Connect(string, Handler) *Route
+	Trace(string, Handler) *Route
+	Handle(string, string, Handler) *Route
+	Match([]string, string, Handler) *Route
+	Remove(string, string) bool
+	Mount(string, http.Handler) *Route
 }
 
 // RouteGroup struct containing all fields and methods for use.
 type RouteGroup struct {
-	lars *LARS
+	prefix string
+	host   *hostRouter
+	chain  *chain
+	lars   *LARS
 }
 
 var _ IRouteGroup = &RouteGroup{}
 
-// Use adds a middleware handler to the group middleware chain.
+// Use adds a middleware handler to the end of the group middleware chain,
+// running after any inherited from the parent group. Routes already
+// registered, including those of groups inheriting from this one, are
+// updated to include it.
 func (g *RouteGroup) Use(m ...Middleware) {
+	if len(m) == 0 {
+		return
+	}
+
+	c := g.chain
+	mw := make([]MiddlewareFunc, 0, len(m))
+	names := make([]string, 0, len(m))
+
 	for _, h := range m {
-		g.lars.middleware = append(g.lars.middleware, wrapMiddleware(h))
+		mw = append(mw, wrapMiddleware(h))
+		names = append(names, handlerName(h))
 	}
+
+	g.lars.router.rebuild(func() {
+		c.after = append(c.after, mw...)
+		c.afterNames = append(c.afterNames, names...)
+	})
+}
+
+// UseBefore adds a middleware handler to the start of the group middleware
+// chain, running before any inherited from the parent group and any
+// previously added using UseBefore. Routes already registered, including
+// those of groups inheriting from this one, are updated to include it.
+func (g *RouteGroup) UseBefore(m ...Middleware) {
+	if len(m) == 0 {
+		return
+	}
+
+	c := g.chain
+	mw := make([]MiddlewareFunc, 0, len(m))
+	names := make([]string, 0, len(m))
+
+	for _, h := range m {
+		mw = append(mw, wrapMiddleware(h))
+		names = append(names, handlerName(h))
+	}
+
+	g.lars.router.rebuild(func() {
+		c.before = append(mw, c.before...)
+		c.beforeNames = append(names, c.beforeNames...)
+	})
 }
 
 // Connect adds a CONNECT route & handler to the router.
-func (g *RouteGroup) Connect(path string, h Handler) {
-	g.lars.add(CONNECT, path, h)
+func (g *RouteGroup) Connect(path string, h Handler) *Route {
+	return g.newRoute(g.add(CONNECT, path, h))
 }
 
 // Delete adds a DELETE route & handler to the router.
-func (g *RouteGroup) Delete(path string, h Handler) {
-	g.lars.add(DELETE, path, h)
+func (g *RouteGroup) Delete(path string, h Handler) *Route {
+	return g.newRoute(g.add(DELETE, path, h))
 }
 
 // Get adds a GET route & handler to the router.
-func (g *RouteGroup) Get(path string, h Handler) {
-	g.lars.add(GET, path, h)
+func (g *RouteGroup) Get(path string, h Handler) *Route {
+	return g.newRoute(g.add(GET, path, h))
 }
 
 // Head adds a HEAD route & handler to the router.
-func (g *RouteGroup) Head(path string, h Handler) {
-	g.lars.add(HEAD, path, h)
+func (g *RouteGroup) Head(path string, h Handler) *Route {
+	return g.newRoute(g.add(HEAD, path, h))
 }
 
 // Options adds an OPTIONS route & handler to the router.
-func (g *RouteGroup) Options(path string, h Handler) {
-	g.lars.add(OPTIONS, path, h)
+func (g *RouteGroup) Options(path string, h Handler) *Route {
+	return g.newRoute(g.add(OPTIONS, path, h))
 }
 
 // Patch adds a PATCH route & handler to the router.
-func (g *RouteGroup) Patch(path string, h Handler) {
-	g.lars.add(PATCH, path, h)
+func (g *RouteGroup) Patch(path string, h Handler) *Route {
+	return g.newRoute(g.add(PATCH, path, h))
 }
 
 // Post adds a POST route & handler to the router.
-func (g *RouteGroup) Post(path string, h Handler) {
-	g.lars.add(POST, path, h)
+func (g *RouteGroup) Post(path string, h Handler) *Route {
+	return g.newRoute(g.add(POST, path, h))
 }
 
 // Put adds a PUT route & handler to the router.
-func (g *RouteGroup) Put(path string, h Handler) {
-	g.lars.add(PUT, path, h)
+func (g *RouteGroup) Put(path string, h Handler) *Route {
+	return g.newRoute(g.add(PUT, path, h))
 }
 
 // Trace adds a TRACE route & handler to the router.
-func (g *RouteGroup) Trace(path string, h Handler) {
-	g.lars.add(TRACE, path, h)
+func (g *RouteGroup) Trace(path string, h Handler) *Route {
+	return g.newRoute(g.add(TRACE, path, h))
+}
+
+// Handle adds a route & handler to the router for the provided HTTP method,
+// which may be any valid method token including extension methods such as
+// WebDAV's PROPFIND or MKCOL.
+func (g *RouteGroup) Handle(method string, path string, h Handler) *Route {
+	return g.newRoute(g.add(method, path, h))
 }
 
-// Any adds a route & handler to the router for all HTTP methods.
-func (g *RouteGroup) Any(path string, h Handler) {
+// Any adds a route & handler to the router for all HTTP methods, including
+// extension methods not explicitly registered on the route.
+func (g *RouteGroup) Any(path string, h Handler) *Route {
+	return g.newRoute(g.any(path, h)...)
+}
+
+// any registers the route for all HTTP methods, returning its details for
+// each.
+func (g *RouteGroup) any(path string, h Handler) []*RouteInfo {
+	routes := make([]*RouteInfo, 0, len(methods)+1)
 	for _, m := range methods {
-		g.lars.add(m, path, h)
+		routes = append(routes, g.add(m, path, h))
 	}
+	return append(routes, g.addAny(methodAny, path, h))
 }
 
 // Match adds a route & handler to the router for multiple HTTP methods provided.
-func (g *RouteGroup) Match(methods []string, path string, h Handler) {
+func (g *RouteGroup) Match(methods []string, path string, h Handler) *Route {
+	routes := make([]*RouteInfo, 0, len(methods))
 	for _, m := range methods {
-		g.lars.add(m, path, h)
+		routes = append(routes, g.add(m, path, h))
 	}
+	return g.newRoute(routes...)
+}
+
+// Remove removes the route registered for the HTTP method and path, as they
+// were registered with the group, reporting whether it existed. The method
+// "*" removes the handler serving extension methods of a route registered
+// using Any. Routes may be added and removed while requests are being served;
+// requests already being routed are unaffected.
+func (g *RouteGroup) Remove(method string, path string) bool {
+	return g.lars.router.removeRoute(method, g.prefix+path, g)
+}
+
+// Mount delegates all requests, for any method, to prefix and every path
+// beneath it to the http.Handler, such as another lars instance or
+// net/http/pprof, after stripping the group's prefix and the provided prefix
+// from the request's URL; prefixes may contain parameters. The group's
+// middleware is applied and the request's context is the lars Context, so
+// cancellation propagates to the handler. The Route returned is that of the
+// prefix itself, so naming it allows generating the mount point's URI.
+func (g *RouteGroup) Mount(prefix string, h http.Handler) *Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	fn := func(c *Context) {
+		r := c.Request.WithContext(c)
+
+		// the remainder of the path is that matched by the match-any
+		// segment, or the root when the prefix itself was requested
+		u := *r.URL
+		u.Path = "/" + c.Param(catchAllName(""))
+		u.RawPath = rawSuffix(r.URL.EscapedPath(), u.Path)
+
+		r.URL = &u
+		r.RequestURI = u.RequestURI()
+
+		h.ServeHTTP(c.Response, r)
+	}
+
+	routes := g.any(prefix+"/*", fn)
+	mount := routes
+
+	if prefix != "" {
+		mount = g.any(prefix, fn)
+		routes = append(routes, mount...)
+	}
+
+	// routes are reported as served by the mounted handler
+	g.lars.router.mu.Lock()
+	for _, rt := range routes {
+		rt.Handler = handlerName(h)
+	}
+	g.lars.router.mu.Unlock()
+
+	return g.newRoute(mount...)
+}
+
+// rawSuffix returns the suffix of the escaped path raw that decodes to p, for
+// use as the URL's RawPath, or "" when p's default encoding is that suffix or
+// none decodes to it.
+func rawSuffix(raw, p string) string {
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '/' {
+			continue
+		}
+		if s, err := url.PathUnescape(raw[i:]); err == nil && s == p {
+			if raw[i:] == p {
+				return ""
+			}
+			return raw[i:]
+		}
+	}
+	return ""
+}
+
+// NotFound registers the handler used for requests that don't match a route,
+// in place of the one registered using LARS.RegisterNotFoundFunc, when the
+// group's prefix is the longest to match the request's path. The handler is
+// run through the group's middleware. It panics when the group's prefix has
+// parameters.
+func (g *RouteGroup) NotFound(h Handler) {
+	notFound := wrapHandler(h)
+
+	g.setGroupHandler(func(r *router) {
+		g.chain.notFoundHandler = notFound
+		r.notFound = addGroup(r.notFound, g)
+	})
+}
+
+// MethodNotAllowed registers the handler used for requests matching a route's
+// path but none of its methods, when the group's prefix is the longest to
+// match the request's path. The Allow header is set before the handler is
+// called. The handler is run through the group's middleware. It panics when
+// the group's prefix has parameters.
+func (g *RouteGroup) MethodNotAllowed(h Handler) {
+	notAllowed := wrapHandler(h)
+
+	g.setGroupHandler(func(r *router) {
+		g.chain.methodNotAllowedHandler = func(c *Context) {
+			c.Response.Header().Set(Allow, c.allow)
+			notAllowed(c)
+		}
+		r.methodNotAllowed = addGroup(r.methodNotAllowed, g)
+	})
+}
+
+// setGroupHandler runs fn, which sets one of the group's handlers and adds the
+// group to those of the router it's matched by, holding the router's mu and
+// then publishes the changes.
+func (g *RouteGroup) setGroupHandler(fn func(*router)) {
+	// groups are matched by the prefix of the request's path
+	if strings.ContainsAny(g.prefix, ":*") {
+		panic("lars => group handlers can't be registered on group '" + g.prefix + "' whose prefix has parameters")
+	}
+
+	r := g.lars.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tree := g.tree()
+
+	fn(tree)
+	g.chain.compile(r.lars)
+	tree.publish()
 }
 
 // Group creates a new sub router with prefix. It inherits all properties from
-// the parent. Passing middleware overrides parent middleware.
+// the parent, including its middleware, which runs before any passed. As
+// middleware is inherited rather than copied, middleware later added to the
+// parent also applies to the group's routes.
 func (g *RouteGroup) Group(prefix string, m ...Middleware) IRouteGroup {
-	l := *g.lars
-	ng := &RouteGroup{&l}
-	ng.lars.prefix += prefix
+	return g.group(prefix, true, m)
+}
 
-	if len(m) == 0 {
-		mw := make([]MiddlewareFunc, len(ng.lars.middleware))
-		copy(mw, ng.lars.middleware)
-		ng.lars.middleware = mw
+// GroupWithNone creates a new sub router with prefix. It inherits all
+// properties from the parent except its middleware, only the middleware
+// passed, or later added to the group, applies to its routes.
+func (g *RouteGroup) GroupWithNone(prefix string, m ...Middleware) IRouteGroup {
+	return g.group(prefix, false, m)
+}
 
-		return ng
+func (g *RouteGroup) group(prefix string, inherit bool, m []Middleware) *RouteGroup {
+	ng := &RouteGroup{
+		prefix: g.prefix + prefix,
+		host:   g.host,
+		chain:  g.lars.router.newChain(g.chain, inherit),
+		lars:   g.lars,
 	}
-
-	ng.lars.middleware = nil
 	ng.Use(m...)
 
 	return ng
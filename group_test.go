@@ -2,6 +2,7 @@ package lars
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	. "gopkg.in/go-playground/assert.v1"
@@ -89,3 +90,225 @@ func TestGroup(t *testing.T) {
 	Equal(t, c, http.StatusOK)
 	Equal(t, s, "")
 }
+
+func TestGroupMount(t *testing.T) {
+	l := New()
+	buf := ""
+
+	l.Use(func(c *Context) {
+		buf += "root;"
+	})
+
+	sub := New()
+	sub.Get("/", func(c *Context) {
+		c.Response.Write([]byte("sub index"))
+	})
+	sub.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte("sub user " + c.Param("id")))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/raw/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + "|" + r.URL.EscapedPath() + "|" + r.RequestURI))
+	})
+
+	l.Mount("/sub/", sub)
+
+	g := l.Group("/debug", func(c *Context) {
+		buf += "group;"
+	})
+	g.Mount("/mux", mux)
+
+	code, body := request(GET, "/sub", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub index")
+	Equal(t, buf, "root;")
+
+	code, body = request(GET, "/sub/", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub index")
+
+	code, body = request(DELETE, "/sub/users/1", l)
+	Equal(t, code, http.StatusMethodNotAllowed)
+
+	code, body = request(GET, "/sub/users/1", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub user 1")
+
+	buf = ""
+	code, body = request("PROPFIND", "/debug/mux/raw/a%2Fb?x=1", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "/raw/a/b|/raw/a%2Fb|/raw/a%2Fb?x=1")
+	Equal(t, buf, "root;group;")
+
+	code, _ = request(GET, "/debug/other", l)
+	Equal(t, code, http.StatusNotFound)
+
+	// mounting at the root of a group
+	l2 := New()
+	l2.Group("/api").Mount("", sub)
+
+	code, body = request(GET, "/api/users/2", l2)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub user 2")
+
+	// prefixes with parameters are stripped as matched
+	l3 := New()
+	l3.Group("/accounts/:account").Mount("/sub", sub).Name("sub")
+
+	code, body = request(GET, "/accounts/acme/sub/users/3", l3)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub user 3")
+
+	code, body = request(GET, "/accounts/acme/sub", l3)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "sub index")
+
+	uri, err := l3.URIFor("sub", "acme")
+	Equal(t, err, nil)
+	Equal(t, uri, "/accounts/acme/sub")
+
+	routes := l3.Routes()
+	Equal(t, routes[0].Handler, "*lars.LARS")
+	Equal(t, routes[len(routes)-1].Method, "*")
+	Equal(t, routes[len(routes)-1].Path, "/accounts/:account/sub")
+	Equal(t, routes[len(routes)-1].Name, "sub")
+}
+
+func TestGroupMiddleware(t *testing.T) {
+	l := New()
+	buf := ""
+
+	mw := func(s string) func(*Context) {
+		return func(*Context) {
+			buf += s
+		}
+	}
+	h := func(*Context) {}
+
+	l.Get("/", h)
+
+	g := l.Group("/g", mw("g"))
+	g.Get("/", h)
+
+	nested := g.Group("/nested")
+	nested.UseBefore(mw("b"))
+	nested.Use(mw("n"))
+	nested.Get("/", h)
+
+	none := g.GroupWithNone("/none", mw("x"))
+	none.Get("/", h)
+
+	// middleware added to a parent applies to existing routes and groups
+	l.Use(mw("r"))
+	g.UseBefore(mw("1"), mw("2"))
+	g.UseBefore(mw("0"))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/", "r"},
+		{"/g/", "012rg"},
+		{"/g/nested/", "b012rgn"},
+		{"/g/none/", "x"},
+	}
+
+	for _, tt := range tests {
+		buf = ""
+		code, _ := request(GET, tt.path, l)
+		Equal(t, code, http.StatusOK)
+		Equal(t, buf, tt.expected)
+	}
+
+	// unmatched methods run through the group's middleware
+	buf = ""
+	code, _ := request(POST, "/g/none/", l)
+	Equal(t, code, http.StatusMethodNotAllowed)
+	Equal(t, buf, "x")
+
+	buf = ""
+	code, _ = request(GET, "/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, buf, "r")
+
+	routes := l.Routes()
+	Equal(t, len(routes[2].Middleware), 7)
+	Equal(t, len(routes[3].Middleware), 1)
+}
+
+func TestGroupErrorHandlers(t *testing.T) {
+	l := New()
+	buf := ""
+
+	l.RegisterNotFoundFunc(func(c *Context) {
+		c.HTML(http.StatusNotFound, "<h1>Not Found</h1>")
+	})
+	l.Get("/", func(*Context) {})
+
+	api := l.Group("/api", func(*Context) {
+		buf += "api;"
+	})
+	api.NotFound(func(c *Context) error {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+	api.MethodNotAllowed(func(c *Context) error {
+		return c.JSON(http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+	})
+	api.Get("/users", func(*Context) {})
+
+	v1 := api.Group("/v1")
+	v1.Get("/users", func(*Context) {})
+
+	// groups without custom handlers use the defaults
+	l.Group("/plain").Post("/users", func(*Context) {})
+
+	code, body := request(GET, "/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "<h1>Not Found</h1>")
+	Equal(t, buf, "")
+
+	code, body = request(GET, "/api/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "{\"message\":\"not found\"}\n")
+	Equal(t, buf, "api;")
+
+	code, body = request(GET, "/api/v1/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "{\"message\":\"not found\"}\n")
+
+	code, body = request(GET, "/apiary", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "<h1>Not Found</h1>")
+
+	buf = ""
+	r, _ := http.NewRequest(DELETE, "/api/v1/users", nil)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Body.String(), "{\"message\":\"method not allowed\"}\n")
+	Equal(t, w.Header().Get(Allow), GET)
+	Equal(t, buf, "api;")
+
+	code, body = request(GET, "/plain/users", l)
+	Equal(t, code, http.StatusMethodNotAllowed)
+	Equal(t, body, "405 method not allowed\n")
+
+	// the most specific group wins
+	v1.NotFound(func(c *Context) {
+		c.String(http.StatusNotFound, "v1 not found")
+	})
+
+	code, body = request(GET, "/api/v1/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "v1 not found")
+
+	code, body = request(GET, "/api/missing", l)
+	Equal(t, code, http.StatusNotFound)
+	Equal(t, body, "{\"message\":\"not found\"}\n")
+
+	// groups are matched by prefix, which can't have parameters
+	users := l.Group("/users/:id")
+	PanicMatches(t, func() { users.NotFound(func(*Context) {}) }, "lars => group handlers can't be registered on group '/users/:id' whose prefix has parameters")
+	PanicMatches(t, func() { users.MethodNotAllowed(func(*Context) {}) }, "lars => group handlers can't be registered on group '/users/:id' whose prefix has parameters")
+}
@@ -0,0 +1,182 @@
+package lars
+
+import "strings"
+
+// hostRouter is the router for routes registered on a host pattern, each host
+// pattern has its own tree so that path lookups are unaffected by host
+// routing.
+type hostRouter struct {
+	*router
+	pattern string
+	labels  []string
+	pnames  []string
+}
+
+// newHostRouter parses the host pattern, whose labels beginning with ':' are
+// parameters matching any single label.
+func newHostRouter(pattern string, l *LARS) *hostRouter {
+	pattern = strings.ToLower(pattern)
+
+	if pattern == "" {
+		panic("lars => empty host pattern")
+	}
+
+	hr := &hostRouter{
+		router:  newRouter(l),
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+	}
+
+	for _, label := range hr.labels {
+		if label == "" || label == ":" {
+			panic("lars => invalid host pattern '" + pattern + "'")
+		}
+		if label[0] == ':' {
+			hr.pnames = append(hr.pnames, label[1:])
+		}
+	}
+
+	return hr
+}
+
+// match reports whether host matches the pattern, storing any parameters
+// on the Context when it does.
+func (hr *hostRouter) match(host string, ctx *Context) bool {
+	for i, label := range hr.labels {
+		j := strings.IndexByte(host, '.')
+		if j == -1 {
+			j = len(host)
+		}
+
+		// remaining labels must consume the whole host
+		if (j == len(host)) != (i == len(hr.labels)-1) {
+			ctx.hvalues = ctx.hvalues[:0]
+			return false
+		}
+
+		if label[0] == ':' {
+			ctx.hvalues = append(ctx.hvalues, host[:j])
+		} else if !strings.EqualFold(label, host[:j]) {
+			ctx.hvalues = ctx.hvalues[:0]
+			return false
+		}
+
+		if j < len(host) {
+			host = host[j+1:]
+		}
+	}
+
+	ctx.hnames = hr.pnames
+
+	return true
+}
+
+// hostRouter returns the router for the host pattern, creating it if it
+// doesn't exist. Patterns without parameters are matched first.
+func (r *router) hostRouter(pattern string) *hostRouter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hr := range r.hosts {
+		if hr.pattern == strings.ToLower(pattern) {
+			return hr
+		}
+	}
+
+	hr := newHostRouter(pattern, r.lars)
+
+	i := len(r.hosts)
+	if len(hr.pnames) == 0 {
+		for i = 0; i < len(r.hosts) && len(r.hosts[i].pnames) == 0; i++ {
+		}
+	}
+
+	// a new slice is used as the current one may be in use serving requests
+	hosts := make([]*hostRouter, 0, len(r.hosts)+1)
+	hosts = append(hosts, r.hosts[:i]...)
+	hosts = append(hosts, hr)
+	r.hosts = append(hosts, r.hosts[i:]...)
+
+	r.liveHosts.Store(r.hosts)
+
+	return hr
+}
+
+// route returns the handler for the request; routes registered for the host
+// pattern matching host take precedence, paths they don't match falling back
+// to the routes registered without one. The host pattern's not found handler
+// is used when neither match.
+func (r *router) route(host, method, path string, ctx *Context) (HandlerFunc, *RouteGroup) {
+	hr := r.findRouter(host, ctx)
+	if hr == r {
+		return r.find(method, path, ctx)
+	}
+
+	hs := hr.snapshot()
+
+	if h, g := hr.lookup(hs, method, path, ctx); h != nil {
+		return h, g
+	}
+
+	// the host's parameters don't apply to routes registered without one
+	hnames, hvalues := ctx.hnames, ctx.hvalues
+	ctx.hnames, ctx.hvalues = nil, ctx.hvalues[:0]
+
+	if h, g := r.lookup(r.snapshot(), method, path, ctx); h != nil {
+		return h, g
+	}
+
+	ctx.hnames, ctx.hvalues = hnames, hvalues
+
+	return hr.findNotFound(hs, path)
+}
+
+// findRouter returns the router whose host pattern matches host, falling back
+// to r for requests not matching any host pattern.
+func (r *router) findRouter(host string, ctx *Context) *router {
+	ctx.hnames = nil
+	ctx.hvalues = ctx.hvalues[:0]
+
+	hosts := r.liveHosts.Load().([]*hostRouter)
+
+	if len(hosts) == 0 {
+		return r
+	}
+
+	// strip port
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	// a fully qualified host, eg. example.com., is the same host
+	host = strings.TrimSuffix(host, ".")
+
+	for _, hr := range hosts {
+		if hr.match(host, ctx) {
+			return hr.router
+		}
+	}
+
+	return r
+}
+
+// Host creates a new route group whose routes only match requests whose Host
+// header matches the pattern, ignoring any port. Labels of the pattern
+// beginning with ':' are parameters, available via Context.Param, that match
+// any single label; eg. ":tenant.example.com". Requests that don't match any
+// host pattern, or any of its routes, are served by the routes registered
+// without one.
+func (l *LARS) Host(pattern string) IRouteGroup {
+	ng := l.group("", true, nil)
+	ng.host = l.router.hostRouter(pattern)
+	return ng
+}
+
+// tree returns the router the group's routes are registered with, which for
+// groups created using Host is that of the host pattern.
+func (g *RouteGroup) tree() *router {
+	if g.host != nil {
+		return g.host.router
+	}
+	return g.lars.router
+}
@@ -0,0 +1,90 @@
+package lars
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func hostRequest(method, host, path string, l *LARS) (int, string) {
+	r, _ := http.NewRequest(method, path, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+
+	return w.Code, w.Body.String()
+}
+
+func hostAdminHandler(c *Context) {
+	c.Response.Write([]byte("admin:" + c.Param("id")))
+}
+
+func TestHost(t *testing.T) {
+	l := New()
+
+	l.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte("default:" + c.Param("id")))
+	})
+
+	// paths not registered on a host fall back to the routes without one
+	l.Get("/health", func(c *Context) {
+		c.Response.Write([]byte("health:" + c.Param("tenant")))
+	})
+
+	tenant := l.Host(":tenant.example.com")
+	tenant.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte(c.Param("tenant") + ":" + c.Param("id")))
+	})
+
+	admin := l.Host("admin.example.com")
+	admin.Get("/users/:id", hostAdminHandler)
+
+	api := l.Host(":version.api.:tenant.example.com").Group("/v")
+	api.Get("/users", func(c *Context) {
+		c.Response.Write([]byte(c.Param("version") + ":" + c.Param("tenant")))
+	})
+
+	// same pattern returns the same tree
+	l.Host(":TENANT.example.com").Get("/files", func(c *Context) {
+		c.Response.Write([]byte("files:" + c.Param("tenant")))
+	})
+
+	tests := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"example.com", "/users/1", http.StatusOK, "default:1"},
+		{"acme.example.com", "/users/2", http.StatusOK, "acme:2"},
+		{"acme.example.com:8080", "/users/3", http.StatusOK, "acme:3"},
+		{"ADMIN.example.com", "/users/4", http.StatusOK, "admin:4"},
+		{"v1.api.acme.example.com", "/v/users", http.StatusOK, "v1:acme"},
+		{"acme.example.com", "/files", http.StatusOK, "files:acme"},
+		{"acme.example.com", "/missing", http.StatusNotFound, "404 page not found\n"},
+		{"acme.example.com.", "/users/8", http.StatusOK, "acme:8"},
+		{"acme.example.com.:8080", "/users/9", http.StatusOK, "acme:9"},
+		{"admin.example.com", "/health", http.StatusOK, "health:"},
+		{"acme.example.com", "/health", http.StatusOK, "health:"},
+		{"a.b.example.com", "/users/5", http.StatusOK, "default:5"},
+		{"example.org", "/users/6", http.StatusOK, "default:6"},
+		{"[::1]:8080", "/users/7", http.StatusOK, "default:7"},
+	}
+
+	for _, tt := range tests {
+		code, body := hostRequest(GET, tt.host, tt.path, l)
+		Equal(t, code, tt.code)
+		Equal(t, body, tt.body)
+	}
+
+	// host routes don't conflict with the same path on other hosts
+	routes := l.Routes()
+	Equal(t, routes[2].Host, ":tenant.example.com")
+	Equal(t, routes[2].Path, "/users/:id")
+
+	PanicMatches(t, func() { admin.Get("/users/:uid", conflictHandlerA) }, "lars => route 'GET admin.example.com/users/:uid' (github.com/go-playground/lars.conflictHandlerA) conflicts with existing route 'GET admin.example.com/users/:id' (github.com/go-playground/lars.hostAdminHandler)")
+	PanicMatches(t, func() { l.Host("") }, "lars => empty host pattern")
+	PanicMatches(t, func() { l.Host("a..com") }, "lars => invalid host pattern 'a..com'")
+}
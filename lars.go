@@ -3,39 +3,97 @@ package lars
 import (
 	"bytes"
 	"fmt"
+	"mime"
 	"net/http"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // LARS struct containing all fields and methods for use
 type LARS struct {
 	RouteGroup
-	prefix     string
-	middleware []MiddlewareFunc
 	maxParam   *int
 	pool       sync.Pool
 	router     *router
 	http404    HandlerFunc
+	httpError  ErrorHandlerFunc
+	renderer   Renderer
 	newGlobals GlobalsFunc
 
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
 	// client is redirected to /foo with http status code 301 for GET requests
-	// and 307 for all other request methods.
+	// and 307 for all other request methods. The query string and any escaped
+	// characters of the path are preserved.
 	// Order of checks:
-	// > Attempts to find the same path but all lowercase
+	// > Attempts to find the cleaned path, when CleanPath is enabled
 	// > Attempts to find by adding or removing slash
+	// > Attempts to find the same path ignoring case, when FixCase is enabled
 	// > Falls Back to Not Found Handler
 	FixTrailingSlash bool
+
+	// Enables automatic redirection if the current route can't be matched but a
+	// route matching the path while ignoring the case of its static segments
+	// exists, eg. /Users/John to /users/John; parameter values are kept as is.
+	FixCase bool
+
+	// Enables automatic redirection if the current route can't be matched but a
+	// route exists for the cleaned path, as path.Clean, with repeated slashes
+	// and '.' and '..' elements removed, eg. /users//../files/ to /files/.
+	CleanPath bool
+
+	// Serves the route matched by FixTrailingSlash, FixCase or CleanPath
+	// directly instead of redirecting the client to the fixed path.
+	ServeFixedPath bool
+
+	// Routes requests using the escaped path, URL.EscapedPath, instead of the
+	// decoded URL.Path, so that an encoded slash within a parameter's value,
+	// eg. /files/a%2Fb, doesn't split it into separate segments. Static
+	// segments of routes must be registered in their escaped form. Parameter
+	// values are decoded by Context.Param, Context.RawParam returns them as
+	// they appeared in the path.
+	UseEscapedPath bool
+
+	// Enables automatic responses to OPTIONS requests for any registered path,
+	// replying with an Allow header listing the path's registered methods.
+	// Explicitly registered OPTIONS handlers take precedence, allowing the
+	// behaviour to be overridden per route.
+	AutomaticOPTIONS bool
+
+	// Allows registering a route for the same method and path as an existing
+	// route, or one differing only by parameter names, replacing the existing
+	// handler instead of panicking. Intended for tests.
+	AllowRouteOverride bool
+
+	// Enables serving HEAD requests using the GET handler for paths without an
+	// explicitly registered HEAD handler. The response body is discarded, but
+	// the Content-Length header reflects what would have been written.
+	AutomaticHEAD bool
+
+	// Timeout, when greater than zero, is applied to every request's Context;
+	// once elapsed the Context's Done channel is closed and Err returns
+	// context.DeadlineExceeded. Use Context.WithTimeout within middleware to
+	// apply a timeout to individual routes or groups.
+	Timeout time.Duration
 }
 
-type route struct {
-	Method  string
-	Path    string
-	Handler Handler
+// RouteInfo contains the details of a registered route.
+type RouteInfo struct {
+	Name       string
+	Method     string
+	Host       string
+	Path       string
+	Handler    string
+	Params     []string
+	Middleware []string
+	group      *RouteGroup
+	handler    HandlerFunc
+	mhs        []*methodHandler
 }
 
 // Middleware is the type used in registerig middleware.
@@ -54,6 +112,10 @@ type Handler interface{}
 // HandlerFunc is the internal handler type used for handlers.
 type HandlerFunc func(*Context)
 
+// ErrorReturningHandlerFunc is a handler that reports failures by returning
+// an error, which is passed to the registered ErrorHandlerFunc.
+type ErrorReturningHandlerFunc func(*Context) error
+
 // GlobalsFunc is a function that creates a new Global object to be passed around the request
 type GlobalsFunc func() IGlobals
 
@@ -95,6 +157,8 @@ const (
 	TextHTMLCharsetUTF8              = TextHTML + "; " + CharsetUTF8
 	TextPlain                        = "text/plain"
 	TextPlainCharsetUTF8             = TextPlain + "; " + CharsetUTF8
+	TextXML                          = "text/xml"
+	TextXMLCharsetUTF8               = TextXML + "; " + CharsetUTF8
 	MultipartForm                    = "multipart/form-data"
 
 	//---------
@@ -107,7 +171,9 @@ const (
 	// Headers
 	//---------
 
+	Accept             = "Accept"
 	AcceptEncoding     = "Accept-Encoding"
+	Allow              = "Allow"
 	Authorization      = "Authorization"
 	ContentDisposition = "Content-Disposition"
 	ContentEncoding    = "Content-Encoding"
@@ -120,6 +186,10 @@ const (
 	XForwardedFor      = "X-Forwarded-For"
 	XRealIP            = "X-Real-IP"
 
+	// methodAny is the internal method used to register the handler that
+	// serves extension methods on routes registered using Any.
+	methodAny = "*"
+
 	default404Body = "404 page not found"
 	default405Body = "405 method not allowed"
 
@@ -148,32 +218,42 @@ var (
 	}
 
 	methodNotAllowedHandler = func(c *Context) {
+		c.Response.Header().Set(Allow, c.allow)
 		http.Error(c.Response, default405Body, http.StatusMethodNotAllowed)
 	}
+
+	automaticOptionsHandler = func(c *Context) {
+		c.Response.Header().Set(Allow, c.allow)
+		c.Response.WriteHeader(http.StatusOK)
+	}
 )
 
 // New creates an instance of lars.
-// FixTrailingSlash defaults to true
+// FixTrailingSlash and FixCase default to true
 func New() *LARS {
 	l := &LARS{
 		FixTrailingSlash: true,
+		FixCase:          true,
 		maxParam:         new(int),
 		http404:          defaultNotFoundHandler,
+		httpError:        defaultErrorHandler,
 		newGlobals: func() IGlobals {
 			return nil
 		},
 	}
-	l.RouteGroup = RouteGroup{l}
+	l.RouteGroup = RouteGroup{lars: l}
 	l.pool.New = func() interface{} {
 		return &Context{
 			Request:  nil,
 			Response: new(Response),
-			pvalues:  make([]string, *l.maxParam),
+			pvalues:  make([]string, atomic.LoadInt32(&l.router.maxParams)),
 			store:    make(store),
 			Globals:  l.newGlobals(),
 		}
 	}
 	l.router = newRouter(l)
+	l.router.codecs.Store(defaultCodecs())
+	l.chain = l.router.newChain(nil, false)
 
 	return l
 }
@@ -182,7 +262,51 @@ func New() *LARS {
 // Here can set redirecting to about to about/ or about/ to about
 // and all your other SEO needs
 func (l *LARS) RegisterNotFoundFunc(notFound HandlerFunc) {
-	l.http404 = notFound
+	l.router.rebuild(func() {
+		l.http404 = notFound
+	})
+}
+
+// RegisterErrorHandler allows for overriding of the function used to turn
+// errors returned by handlers and middleware into responses.
+func (l *LARS) RegisterErrorHandler(fn ErrorHandlerFunc) {
+	l.httpError = fn
+}
+
+// RegisterRenderer registers the Renderer used by Context.Render.
+func (l *LARS) RegisterRenderer(r Renderer) {
+	l.renderer = r
+}
+
+// RegisterCodec registers the Codec used to encode and decode the media type,
+// eg. application/cbor or application/yaml, replacing any existing Codec for
+// it including those registered by default for application/json,
+// application/xml, text/xml, application/protobuf and application/msgpack.
+// Codecs may be registered while requests are being served.
+func (l *LARS) RegisterCodec(mediaType string, codec Codec) {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		panic("lars => invalid media type '" + mediaType + "'")
+	}
+
+	if codec == nil {
+		panic("lars => nil codec for media type '" + mediaType + "'")
+	}
+
+	r := l.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a new map is used as the current one may be in use serving requests
+	cs := r.codecs.Load().(codecs)
+	ncs := make(codecs, len(cs)+1)
+
+	for k, c := range cs {
+		ncs[k] = c
+	}
+
+	ncs[mt] = codec
+	r.codecs.Store(ncs)
 }
 
 // RegisterGlobalsFunc registers a custom globals function for creation
@@ -191,15 +315,42 @@ func (l *LARS) RegisterGlobalsFunc(fn GlobalsFunc) {
 	l.newGlobals = fn
 }
 
-func (l *LARS) add(method, path string, h Handler) {
-	path = l.prefix + path
-	l.router.add(method, path, wrapHandler(h), l)
-	r := route{
-		Method:  method,
-		Path:    path,
-		Handler: runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name(),
+// add registers the route, returning its details.
+func (g *RouteGroup) add(method, path string, h Handler) *RouteInfo {
+	if !validMethod(method) {
+		panic("lars => invalid method '" + method + "'")
 	}
-	l.router.routes = append(l.router.routes, r)
+	return g.addAny(method, path, h)
+}
+
+// addAny registers the route without validating the method, so that it can
+// be methodAny.
+func (g *RouteGroup) addAny(method, path string, h Handler) *RouteInfo {
+	path = g.prefix + path
+	return g.lars.router.addRoute(method, path, handlerName(h), wrapHandler(h), g)
+}
+
+// Routes returns the details of all registered routes in the order they were
+// registered, including the names of the middleware applied to each. Routes
+// registered using Any include an entry with the method "*", representing
+// the extension methods it serves, which may be passed to Remove.
+func (l *LARS) Routes() []RouteInfo {
+	l.router.mu.Lock()
+	defer l.router.mu.Unlock()
+
+	routes := make([]RouteInfo, len(l.router.routes))
+
+	for i, rp := range l.router.routes {
+		r := *rp
+		r.Params = append(make([]string, 0, len(r.Params)), r.Params...)
+		r.Middleware = r.group.chain.names()
+		r.group = nil
+		r.handler = nil
+		r.mhs = nil
+		routes[i] = r
+	}
+
+	return routes
 }
 
 // URI generates a URI from handler.
@@ -208,6 +359,10 @@ func (l *LARS) URI(h Handler, params ...interface{}) string {
 	pl := len(params)
 	n := 0
 	hn := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+
+	l.router.mu.Lock()
+	defer l.router.mu.Unlock()
+
 	for _, r := range l.router.routes {
 		if r.Handler == hn {
 			for i, l := 0, len(r.Path); i < l; i++ {
@@ -216,6 +371,16 @@ func (l *LARS) URI(h Handler, params ...interface{}) string {
 					}
 					uri.WriteString(fmt.Sprintf("%v", params[n]))
 					n++
+				} else if r.Path[i] == '*' && n < pl {
+					uri.WriteString(fmt.Sprintf("%v", params[n]))
+					n++
+					break
+				} else if r.Path[i] == ':' && optionalSegment(r.Path[i:]) {
+					// omit missing optional parameters
+					if uri.Len() > 1 {
+						uri.Truncate(uri.Len() - 1)
+					}
+					break
 				}
 				if i < l {
 					uri.WriteByte(r.Path[i])
@@ -227,6 +392,15 @@ func (l *LARS) URI(h Handler, params ...interface{}) string {
 	return uri.String()
 }
 
+// optionalSegment reports whether the path segment beginning path is an
+// optional parameter.
+func optionalSegment(path string) bool {
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		path = path[:i]
+	}
+	return strings.HasSuffix(path, "?")
+}
+
 // URL is an alias for `URI` function.
 func (l *LARS) URL(h Handler, params ...interface{}) string {
 	return l.URI(h, params...)
@@ -235,17 +409,30 @@ func (l *LARS) URL(h Handler, params ...interface{}) string {
 // ServeHTTP implements `http.Handler` interface, which serves HTTP requests.
 func (l *LARS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	path := r.URL.Path
+	if l.UseEscapedPath {
+		path = r.URL.EscapedPath()
+	}
+
+	if atomic.LoadInt32(&l.router.serving) == 0 {
+		l.router.serve()
+	}
+
 	c := l.pool.Get().(*Context)
-	h, l := l.router.find(r.Method, r.URL.Path, c)
+
+	h, _ := l.router.route(r.Host, r.Method, path, c)
 	c.reset(r, w, l)
 
-	// Chain middleware with handler in the end
-	for i := len(l.middleware) - 1; i >= 0; i-- {
-		h = l.middleware[i](h)
+	// Execute chain, the handler is already wrapped by its group's middleware
+	h(c)
+
+	if c.err != nil {
+		l.httpError(c, c.err)
 	}
 
-	// Execute chain
-	h(c)
+	// after the error handler, which may set the status of HEAD requests
+	c.Response.writeDeferredHeader()
 
+	c.release()
 	l.pool.Put(c)
 }
@@ -2,6 +2,7 @@ package lars
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -286,11 +287,17 @@ func TestlGroup(t *testing.T) {
 	g1.Get("/", h)
 
 	// Group with no parent middleware
-	g2 := l.Group("/group2", func(*Context) {
+	g2 := l.GroupWithNone("/group2", func(*Context) {
 		buf.WriteString("2")
 	})
 	g2.Get("/", h)
 
+	// Group with additional middleware
+	g5 := l.Group("/group5", func(*Context) {
+		buf.WriteString("5")
+	})
+	g5.Get("/", h)
+
 	// Nested groups
 	g3 := l.Group("/group3")
 	g4 := g3.Group("/group4")
@@ -307,6 +314,10 @@ func TestlGroup(t *testing.T) {
 	request(GET, "/group2/", l)
 	Equal(t, "2", buf.String())
 
+	buf.Reset()
+	request(GET, "/group5/", l)
+	Equal(t, "05", buf.String())
+
 	buf.Reset()
 	c, _ := request(GET, "/group3/group4/", l)
 	Equal(t, http.StatusOK, c)
@@ -343,6 +354,61 @@ func TestMethodNotAllowed(t *testing.T) {
 
 	l.ServeHTTP(w, r)
 	Equal(t, http.StatusMethodNotAllowed, w.Code)
+	Equal(t, w.Header().Get(Allow), "GET")
+
+	l.Put("/", func(ctx *Context) {})
+	l.Delete("/", func(ctx *Context) {})
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, http.StatusMethodNotAllowed, w.Code)
+	Equal(t, w.Header().Get(Allow), "DELETE, GET, PUT")
+}
+
+func TestAutomaticOPTIONS(t *testing.T) {
+	l := New()
+	l.Get("/users", func(ctx *Context) {})
+	l.Post("/users", func(ctx *Context) {})
+	l.Get("/custom", func(ctx *Context) {})
+	l.Options("/custom", func(ctx *Context) {
+		ctx.Response.Header().Set(Allow, "GET")
+		ctx.Response.WriteHeader(http.StatusNoContent)
+	})
+
+	// disabled by default
+	r, _ := http.NewRequest(OPTIONS, "/users", nil)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "GET, POST")
+
+	l.AutomaticOPTIONS = true
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Header().Get(Allow), "GET, OPTIONS, POST")
+	Equal(t, w.Body.String(), "")
+
+	// 405 advertises OPTIONS once enabled
+	r, _ = http.NewRequest(PUT, "/users", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "GET, OPTIONS, POST")
+
+	// explicitly registered OPTIONS handler wins
+	r, _ = http.NewRequest(OPTIONS, "/custom", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusNoContent)
+	Equal(t, w.Header().Get(Allow), "GET")
+
+	// unknown paths are still not found
+	r, _ = http.NewRequest(OPTIONS, "/unknown", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusNotFound)
 }
 
 func testMethod(t *testing.T, method, path string, l *LARS) {
@@ -366,3 +432,154 @@ func request(method, path string, l *LARS) (int, string) {
 
 	return w.Code, w.Body.String()
 }
+
+// newTestContext returns a Context reset for a request with the body, for
+// testing its methods outside of a handler.
+func newTestContext(method, path, contentType, body string) *Context {
+	l := New()
+	c := l.pool.New().(*Context)
+	r, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set(ContentType, contentType)
+	}
+	c.reset(r, httptest.NewRecorder(), l)
+	return c
+}
+
+func TestAutomaticHEAD(t *testing.T) {
+	l := New()
+	l.Get("/users", func(ctx *Context) {
+		ctx.Response.Header().Set(ContentType, TextPlain)
+		ctx.Response.Write([]byte("joey"))
+		ctx.Response.WriteString("bloggs")
+	})
+	l.Get("/empty", func(ctx *Context) {
+		ctx.Response.WriteHeader(http.StatusNoContent)
+	})
+	l.Get("/explicit", func(ctx *Context) {})
+	l.Get("/error", func(ctx *Context) error {
+		return errors.New("boom")
+	})
+	l.Get("/flush", func(ctx *Context) {
+		ctx.Response.Flush()
+		ctx.Response.WriteHeader(http.StatusAccepted)
+	})
+	l.Head("/explicit", func(ctx *Context) {
+		ctx.Response.Header().Set("X-Head", "explicit")
+	})
+	l.Post("/post", func(ctx *Context) {})
+
+	// disabled by default
+	r, _ := http.NewRequest(HEAD, "/users", nil)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "GET")
+
+	l.AutomaticHEAD = true
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Body.String(), "")
+	Equal(t, w.Header().Get(ContentLength), "10")
+	Equal(t, w.Header().Get(ContentType), TextPlain)
+
+	r, _ = http.NewRequest(HEAD, "/empty", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusNoContent)
+	Equal(t, w.Header().Get(ContentLength), "0")
+
+	r, _ = http.NewRequest(HEAD, "/explicit", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Header().Get("X-Head"), "explicit")
+
+	r, _ = http.NewRequest(HEAD, "/post", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "POST")
+
+	// errors returned by the GET handler set the status
+	r, _ = http.NewRequest(HEAD, "/error", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusInternalServerError)
+	Equal(t, w.Body.String(), "")
+
+	code, _ := request(GET, "/error", l)
+	Equal(t, code, http.StatusInternalServerError)
+
+	// flushing doesn't send the withheld header
+	r, _ = http.NewRequest(HEAD, "/flush", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusAccepted)
+	Equal(t, w.Flushed, false)
+
+	// GET requests are unaffected
+	code, body := request(GET, "/users", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "joeybloggs")
+
+	// 405 advertises HEAD for GET routes
+	r, _ = http.NewRequest(PUT, "/users", nil)
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "GET, HEAD")
+
+	l.AutomaticOPTIONS = true
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Header().Get(Allow), "GET, HEAD, OPTIONS")
+}
+
+func routesMiddleware(h HandlerFunc) HandlerFunc {
+	return h
+}
+
+func routesGroupMiddleware(c *Context) {}
+
+func routesHandler(c *Context) {}
+
+func TestRoutesInfo(t *testing.T) {
+	l := New()
+	l.Use(routesMiddleware)
+	l.Get("/users/:id<int>/files/:name", routesHandler)
+
+	g := l.Group("/admin")
+	g.Use(routesGroupMiddleware)
+	g.Post("/static/*", routesHandler)
+
+	g2 := l.GroupWithNone("/isolated", routesGroupMiddleware)
+	g2.Handle("PURGE", "/cache", http.NotFoundHandler())
+
+	routes := l.Routes()
+	Equal(t, len(routes), 3)
+
+	Equal(t, routes[0].Method, GET)
+	Equal(t, routes[0].Path, "/users/:id<int>/files/:name")
+	Equal(t, routes[0].Handler, "github.com/go-playground/lars.routesHandler")
+	Equal(t, routes[0].Params, []string{"id", "name"})
+	Equal(t, routes[0].Middleware, []string{"github.com/go-playground/lars.routesMiddleware"})
+
+	Equal(t, routes[1].Method, POST)
+	Equal(t, routes[1].Path, "/admin/static/*")
+	Equal(t, routes[1].Params, []string{"_*"})
+	Equal(t, routes[1].Middleware, []string{"github.com/go-playground/lars.routesMiddleware", "github.com/go-playground/lars.routesGroupMiddleware"})
+
+	Equal(t, routes[2].Method, "PURGE")
+	Equal(t, routes[2].Path, "/isolated/cache")
+	Equal(t, routes[2].Handler, "net/http.NotFound")
+	Equal(t, routes[2].Params, []string{})
+	Equal(t, routes[2].Middleware, []string{"github.com/go-playground/lars.routesGroupMiddleware"})
+
+	// modifying the result doesn't affect the router
+	routes[0].Params[0] = "changed"
+	Equal(t, l.Routes()[0].Params[0], "id")
+}
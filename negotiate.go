@@ -0,0 +1,230 @@
+package lars
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Offer is a media type Negotiate may respond with along with the data sent
+// when it's chosen.
+type Offer struct {
+	MediaType string
+	Data      interface{}
+}
+
+// mediaRange is a single media range of an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	params  map[string]string
+	q       float64
+}
+
+// Accepts returns the one of types best matching the request's Accept header,
+// taking q-values, wildcards and media type parameters into account, or ""
+// when none are acceptable. Types are listed in order of preference, which
+// breaks ties between equally acceptable types; the first is returned when
+// the request has no Accept header.
+//
+// The Vary: Accept header is added to the response.
+func (c *Context) Accepts(types ...string) string {
+	c.varyAccept()
+
+	if i := c.negotiate(types); i != -1 {
+		return types[i]
+	}
+
+	return ""
+}
+
+// Negotiate sends the data of the offer best matching the request's Accept
+// header with status code, as Accepts chooses between the offers' media
+// types. A 406 Not Acceptable *HTTPError is returned when none are acceptable.
+//
+// Data is sent according to the media type:
+// > as is when it's a []byte
+// > using the Codec registered for the media type, see RegisterCodec
+// > text/html and text/plain using fmt.Sprint
+func (c *Context) Negotiate(code int, offers []Offer) error {
+	c.varyAccept()
+
+	types := make([]string, len(offers))
+	for i, o := range offers {
+		types[i] = o.MediaType
+	}
+
+	i := c.negotiate(types)
+	if i == -1 {
+		return NewHTTPError(http.StatusNotAcceptable)
+	}
+
+	return c.encode(code, offers[i].MediaType, offers[i].Data)
+}
+
+// encode sends data encoded as mediaType with status code.
+func (c *Context) encode(code int, mediaType string, data interface{}) error {
+	if b, ok := data.([]byte); ok {
+		return c.Blob(code, mediaType, b)
+	}
+
+	if _, ok := c.Response.lars.router.codec(mediaType); ok {
+		return c.Encode(code, mediaType, data)
+	}
+
+	mt, _, _ := mime.ParseMediaType(mediaType)
+
+	switch mt {
+	case TextHTML:
+		return c.HTML(code, fmt.Sprint(data))
+	case TextPlain:
+		return c.String(code, fmt.Sprint(data))
+	}
+
+	return fmt.Errorf("lars => codec not registered for media type '%s'", mediaType)
+}
+
+// varyAccept adds Accept to the response's Vary header unless already present.
+func (c *Context) varyAccept() {
+	h := c.Response.Header()
+
+	for _, v := range h[Vary] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, Accept) {
+				return
+			}
+		}
+	}
+
+	h.Add(Vary, Accept)
+}
+
+// negotiate returns the index of the best of types for the request's Accept
+// header, or -1 when none are acceptable.
+func (c *Context) negotiate(types []string) int {
+	accept := strings.Join(c.Request.Header[Accept], ",")
+
+	if strings.TrimSpace(accept) == "" {
+		if len(types) == 0 {
+			return -1
+		}
+		return 0
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := -1, 0.0
+
+	for i, t := range types {
+		if q := quality(t, ranges); q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header, skipping those that
+// are malformed.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, s := range strings.Split(accept, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+
+		// some clients send a lone "*"
+		if s == "*" || strings.HasPrefix(s, "*;") {
+			s = "*/*" + s[1:]
+		}
+
+		mt, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := splitMediaType(mt)
+		if !ok || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		r := mediaRange{typ: typ, subtype: subtype, params: params, q: 1}
+
+		if v, ok := params["q"]; ok {
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			r.q = q
+			delete(params, "q")
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// quality returns the q-value of the most specific of ranges matching the
+// media type, or 0 when none match.
+func quality(mediaType string, ranges []mediaRange) float64 {
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return 0
+	}
+
+	typ, subtype, ok := splitMediaType(mt)
+	if !ok {
+		return 0
+	}
+
+	q, specificity := 0.0, -1
+
+	for _, r := range ranges {
+		s := r.match(typ, subtype, params)
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
+
+// match returns how specific the range is when it matches the media type, so
+// that eg. text/html;level=1 takes precedence over text/html, text/* and */*,
+// or -1 when it doesn't match.
+func (r mediaRange) match(typ, subtype string, params map[string]string) int {
+	s := 0
+
+	switch {
+	case r.typ == "*":
+	case r.typ != typ:
+		return -1
+	case r.subtype == "*":
+		s = 100
+	case r.subtype != subtype:
+		return -1
+	default:
+		s = 200
+	}
+
+	for k, v := range r.params {
+		if !strings.EqualFold(params[k], v) {
+			return -1
+		}
+		s++
+	}
+
+	return s
+}
+
+// splitMediaType splits a media type into its type and subtype.
+func splitMediaType(mt string) (typ, subtype string, ok bool) {
+	i := strings.IndexByte(mt, '/')
+	if i <= 0 || i == len(mt)-1 {
+		return "", "", false
+	}
+	return mt[:i], mt[i+1:], true
+}
@@ -0,0 +1,124 @@
+package lars
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestAccepts(t *testing.T) {
+	l := New()
+	l.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, c.Accepts(ApplicationJSON, ApplicationXML, TextHTML, ApplicationMsgpack))
+	})
+
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", ApplicationJSON},
+		{"*/*", ApplicationJSON},
+		{"*", ApplicationJSON},
+		{"application/xml", ApplicationXML},
+		{"text/*", TextHTML},
+		{"text/html, application/json", ApplicationJSON},
+		{"text/html;q=0.9, application/xml", ApplicationXML},
+		{"application/json;q=0.5, application/*;q=0.8", ApplicationXML},
+		{"application/*, application/json;q=0", ApplicationXML},
+		{"*/*;q=0.1, application/msgpack", ApplicationMsgpack},
+		{"Application/MsgPack", ApplicationMsgpack},
+		{"text/html;level=1", ""},
+		{"image/png", ""},
+		{"application/json;q=0, */*;q=0", ""},
+		{"application/json;q=abc, text/html", TextHTML},
+		{"bad, application/xml", ApplicationXML},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(GET, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set(Accept, tt.accept)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Body.String(), tt.expected)
+		Equal(t, w.Header()[Vary], []string{Accept})
+	}
+}
+
+func TestAcceptsParams(t *testing.T) {
+	l := New()
+	l.Get("/", func(c *Context) error {
+		c.Response.Header().Set(Vary, "Origin, accept")
+		return c.String(http.StatusOK, c.Accepts(TextHTML+"; level=1", TextHTML, ApplicationJSONCharsetUTF8))
+	})
+
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"text/html;level=2", ""},
+		{"text/html;level=1;q=0.5, text/html", TextHTML},
+		{"text/html;q=0.5, text/html;level=1", TextHTML + "; level=1"},
+		{"application/json;charset=UTF-8", ApplicationJSONCharsetUTF8},
+		{"application/json;charset=latin1", ""},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(GET, "/", nil)
+		r.Header.Set(Accept, tt.accept)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Body.String(), tt.expected)
+		Equal(t, w.Header()[Vary], []string{"Origin, accept"})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	l := New()
+	u := renderUser{ID: 1, Name: "joeybloggs"}
+
+	l.Get("/", func(c *Context) error {
+		return c.Negotiate(http.StatusOK, []Offer{
+			{ApplicationJSON, u},
+			{ApplicationXML, u},
+			{TextHTML, "<h1>joeybloggs</h1>"},
+			{TextPlain, "joeybloggs"},
+			{ApplicationProtobuf, []byte{0x08, 0x01}},
+		})
+	})
+	l.Get("/unencodable", func(c *Context) error {
+		return c.Negotiate(http.StatusOK, []Offer{{ApplicationMsgpack, u}})
+	})
+
+	tests := []struct {
+		path        string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/", "", http.StatusOK, ApplicationJSONCharsetUTF8, "{\"id\":1,\"name\":\"joeybloggs\"}\n"},
+		{"/", "text/xml, application/xml;q=0.9", http.StatusOK, ApplicationXMLCharsetUTF8, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<renderUser><id>1</id><name>joeybloggs</name></renderUser>"},
+		{"/", "text/html, */*;q=0.8", http.StatusOK, TextHTMLCharsetUTF8, "<h1>joeybloggs</h1>"},
+		{"/", "text/plain", http.StatusOK, TextPlainCharsetUTF8, "joeybloggs"},
+		{"/", "application/protobuf", http.StatusOK, ApplicationProtobuf, "\x08\x01"},
+		{"/", "image/png", http.StatusNotAcceptable, TextPlainCharsetUTF8, "Not Acceptable\n"},
+		{"/unencodable", "", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(GET, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set(Accept, tt.accept)
+		}
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, tt.code)
+		Equal(t, w.Header().Get(ContentType), tt.contentType)
+		Equal(t, w.Header().Get(Vary), Accept)
+		Equal(t, w.Body.String(), tt.body)
+	}
+}
@@ -0,0 +1,195 @@
+package lars
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sources of parameters reported by ParamError
+const (
+	PathParam  = "path"
+	QueryParam = "query"
+)
+
+// Param is the name and value of a path parameter.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// ParamError describes a path or query parameter that's missing or whose
+// value can't be converted to the requested type. The typed accessors return
+// it as the Internal cause of a 400 Bad Request *HTTPError, so it can be
+// returned from handlers as is.
+type ParamError struct {
+	Source string
+	Name   string
+	Value  string
+	Type   string
+	Err    error
+}
+
+// Error returns the error message.
+func (e *ParamError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("missing %s parameter '%s'", e.Source, e.Name)
+	}
+	return fmt.Sprintf("invalid %s parameter '%s', expected %s but got '%s'", e.Source, e.Name, e.Type, e.Value)
+}
+
+// Unwrap returns the conversion error, nil when the parameter is missing.
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// paramError returns the *HTTPError wrapping the *ParamError.
+func paramError(source, name, value, typ string, err error) error {
+	pe := &ParamError{Source: source, Name: name, Value: value, Type: typ, Err: err}
+	return NewHTTPError(http.StatusBadRequest, pe.Error()).SetInternal(pe)
+}
+
+// PathParams returns the name and value of each path parameter, in the order
+// they appear in the route's path. It complements Params, which only returns
+// the names and is kept as is for compatibility.
+func (c *Context) PathParams() []Param {
+	params := make([]Param, len(c.pnames))
+	for i, n := range c.pnames {
+		params[i] = Param{Name: n, Value: c.unescape(c.pvalues[i])}
+	}
+	return params
+}
+
+// pathParam returns path parameter by name, or an error when it's missing or
+// empty, as for an optional parameter that was omitted.
+func (c *Context) pathParam(name, typ string) (string, error) {
+	v := c.Param(name)
+	if v == "" {
+		return "", paramError(PathParam, name, "", typ, nil)
+	}
+	return v, nil
+}
+
+// ParamInt returns path parameter by name as an int.
+func (c *Context) ParamInt(name string) (int, error) {
+	v, err := c.pathParam(name, "int")
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, paramError(PathParam, name, v, "int", err)
+	}
+	return i, nil
+}
+
+// ParamInt64 returns path parameter by name as an int64.
+func (c *Context) ParamInt64(name string) (int64, error) {
+	v, err := c.pathParam(name, "int64")
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, paramError(PathParam, name, v, "int64", err)
+	}
+	return i, nil
+}
+
+// ParamUint returns path parameter by name as a uint.
+func (c *Context) ParamUint(name string) (uint, error) {
+	v, err := c.pathParam(name, "uint")
+	if err != nil {
+		return 0, err
+	}
+	u, err := strconv.ParseUint(v, 10, 0)
+	if err != nil {
+		return 0, paramError(PathParam, name, v, "uint", err)
+	}
+	return uint(u), nil
+}
+
+// ParamBool returns path parameter by name as a bool, accepting the values
+// accepted by strconv.ParseBool.
+func (c *Context) ParamBool(name string) (bool, error) {
+	v, err := c.pathParam(name, "bool")
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, paramError(PathParam, name, v, "bool", err)
+	}
+	return b, nil
+}
+
+// ParamUUID returns path parameter by name after ensuring it's a UUID in its
+// canonical textual form, eg. 123e4567-e89b-12d3-a456-426655440000, lowercased.
+func (c *Context) ParamUUID(name string) (string, error) {
+	v, err := c.pathParam(name, "uuid")
+	if err != nil {
+		return "", err
+	}
+	if !isUUID(v) {
+		return "", paramError(PathParam, name, v, "uuid", fmt.Errorf("invalid uuid '%s'", v))
+	}
+	return strings.ToLower(v), nil
+}
+
+// queryValues returns the parsed query string, parsing it once per request.
+func (c *Context) queryValues() url.Values {
+	if c.query == nil {
+		c.query = c.Request.URL.Query()
+	}
+	return c.query
+}
+
+// QueryDefault returns the first value of the query string parameter, or def
+// when it's missing or empty.
+func (c *Context) QueryDefault(name, def string) string {
+	if v := c.queryValues().Get(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// QuerySlice returns all values of the query string parameter, in the order
+// they appear, eg. ?id=1&id=2; nil is returned when it's missing.
+func (c *Context) QuerySlice(name string) []string {
+	return c.queryValues()[name]
+}
+
+// QueryInt returns the first value of the query string parameter as an int,
+// returning an error when it's missing or invalid.
+func (c *Context) QueryInt(name string) (int, error) {
+	vs, ok := c.queryValues()[name]
+	if !ok || len(vs) == 0 {
+		return 0, paramError(QueryParam, name, "", "int", nil)
+	}
+	i, err := strconv.Atoi(vs[0])
+	if err != nil {
+		return 0, paramError(QueryParam, name, vs[0], "int", err)
+	}
+	return i, nil
+}
+
+// QueryBool returns the first value of the query string parameter as a bool,
+// accepting the values accepted by strconv.ParseBool. A parameter present
+// without a value, eg. ?verbose, is true; an error is returned when it's
+// missing or invalid.
+func (c *Context) QueryBool(name string) (bool, error) {
+	vs, ok := c.queryValues()[name]
+	if !ok || len(vs) == 0 {
+		return false, paramError(QueryParam, name, "", "bool", nil)
+	}
+	if vs[0] == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(vs[0])
+	if err != nil {
+		return false, paramError(QueryParam, name, vs[0], "bool", err)
+	}
+	return b, nil
+}
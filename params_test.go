@@ -0,0 +1,156 @@
+package lars
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestTypedParams(t *testing.T) {
+	l := New()
+
+	var (
+		id   int
+		id64 int64
+		u    uint
+		b    bool
+		uuid string
+		err  error
+	)
+
+	l.Get("/:id/:flag/:uuid", func(c *Context) error {
+		if id, err = c.ParamInt("id"); err != nil {
+			return err
+		}
+		if id64, err = c.ParamInt64("id"); err != nil {
+			return err
+		}
+		if u, err = c.ParamUint("id"); err != nil {
+			return err
+		}
+		if b, err = c.ParamBool("flag"); err != nil {
+			return err
+		}
+		uuid, err = c.ParamUUID("uuid")
+		return err
+	})
+
+	code, _ := request(GET, "/42/true/123E4567-E89B-12D3-A456-426655440000", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, id, 42)
+	Equal(t, id64, int64(42))
+	Equal(t, u, uint(42))
+	Equal(t, b, true)
+	Equal(t, uuid, "123e4567-e89b-12d3-a456-426655440000")
+
+	code, body := request(GET, "/abc/true/x", l)
+	Equal(t, code, http.StatusBadRequest)
+	Equal(t, body, "invalid path parameter 'id', expected int but got 'abc'\n")
+
+	he, ok := err.(*HTTPError)
+	Equal(t, ok, true)
+	pe, ok := he.Internal.(*ParamError)
+	Equal(t, ok, true)
+	Equal(t, pe.Source, PathParam)
+	Equal(t, pe.Name, "id")
+	Equal(t, pe.Value, "abc")
+	Equal(t, pe.Type, "int")
+
+	code, _ = request(GET, "/-1/true/x", l)
+	Equal(t, code, http.StatusBadRequest)
+	Equal(t, err.(*HTTPError).Internal.(*ParamError).Type, "uint")
+
+	code, _ = request(GET, "/1/maybe/x", l)
+	Equal(t, code, http.StatusBadRequest)
+
+	code, body = request(GET, "/1/false/x", l)
+	Equal(t, code, http.StatusBadRequest)
+	Equal(t, body, "invalid path parameter 'uuid', expected uuid but got 'x'\n")
+
+	// control characters aren't case folded into digits
+	code, _ = request(GET, "/1/false/%10%11%12%13%14%15%16%17-1234-1234-1234-123456789012", l)
+	Equal(t, code, http.StatusBadRequest)
+
+	// the conversion error is available by unwrapping
+	request(GET, "/abc/true/x", l)
+	Equal(t, errors.Is(err.(*HTTPError).Internal, strconv.ErrSyntax), true)
+
+	// parameters absent from the route, or omitted optional ones, are missing
+	l.Get("/optional/:n?", func(c *Context) error {
+		_, err = c.ParamInt("n")
+		return err
+	})
+	l.Get("/absent", func(c *Context) error {
+		_, err = c.ParamUUID("id")
+		return err
+	})
+
+	code, body = request(GET, "/optional", l)
+	Equal(t, code, http.StatusBadRequest)
+	Equal(t, body, "missing path parameter 'n'\n")
+	Equal(t, errors.Unwrap(err.(*HTTPError).Internal), nil)
+
+	code, body = request(GET, "/absent", l)
+	Equal(t, code, http.StatusBadRequest)
+	Equal(t, body, "missing path parameter 'id'\n")
+}
+
+func TestTypedQuery(t *testing.T) {
+	l := New()
+
+	l.Get("/", func(c *Context) {
+		page, err := c.QueryInt("page")
+		Equal(t, err, nil)
+		Equal(t, page, 2)
+
+		verbose, err := c.QueryBool("verbose")
+		Equal(t, err, nil)
+		Equal(t, verbose, true)
+
+		debug, err := c.QueryBool("debug")
+		Equal(t, err, nil)
+		Equal(t, debug, false)
+
+		Equal(t, c.QuerySlice("tag"), []string{"a", "b"})
+		Equal(t, len(c.QuerySlice("missing")), 0)
+		Equal(t, c.QueryDefault("tag", "z"), "a")
+		Equal(t, c.QueryDefault("empty", "z"), "z")
+		Equal(t, c.QueryDefault("missing", "z"), "z")
+
+		_, err = c.QueryInt("tag")
+		Equal(t, err.Error(), "code=400, message=invalid query parameter 'tag', expected int but got 'a', internal=invalid query parameter 'tag', expected int but got 'a'")
+
+		_, err = c.QueryInt("missing")
+		pe := err.(*HTTPError).Internal.(*ParamError)
+		Equal(t, pe.Source, QueryParam)
+		Equal(t, pe.Err, nil)
+		Equal(t, pe.Error(), "missing query parameter 'missing'")
+
+		_, err = c.QueryBool("tag")
+		NotEqual(t, err, nil)
+
+		c.Response.Write([]byte("ok"))
+	})
+
+	code, body := request(GET, "/?page=2&verbose&debug=false&tag=a&tag=b&empty=", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "ok")
+}
+
+func TestPathParams(t *testing.T) {
+	l := New()
+	l.UseEscapedPath = true
+
+	var params []Param
+
+	l.Get("/users/:id/files/*", func(c *Context) {
+		params = c.PathParams()
+	})
+
+	code, _ := request(GET, "/users/1/files/a%2Fb/c", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, params, []Param{{Name: "id", Value: "1"}, {Name: "_*", Value: "a/b/c"}})
+}
@@ -0,0 +1,178 @@
+package lars
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// fixPath attempts to find a route for a normalized form of the path, as
+// enabled by the CleanPath, FixTrailingSlash and FixCase options, returning the
+// fixed path along with its handler, or a nil handler when none is found for
+// the method.
+// Order of checks:
+// > Attempts to find the cleaned path
+// > Attempts to find by adding or removing slash
+// > Attempts to find the same path ignoring case, with and without slash
+func (r *router) fixPath(s *snapshot, method, p string, ctx *Context) (fixed string, h HandlerFunc, g *RouteGroup) {
+	fixed = p
+
+	if r.lars.CleanPath {
+		if fixed = cleanPath(p); fixed != p {
+			if h, g = r.findFixed(s, method, fixed, ctx); h != nil {
+				return
+			}
+		}
+	}
+
+	candidates := []string{fixed}
+
+	if r.lars.FixTrailingSlash && fixed != basePath {
+		candidates = append(candidates, toggleTrailingSlash(fixed))
+
+		if h, g = r.findFixed(s, method, candidates[1], ctx); h != nil {
+			return candidates[1], h, g
+		}
+	}
+
+	if r.lars.FixCase {
+		for _, c := range candidates {
+			if b, ok := s.tree.findCaseInsensitive(c, make([]byte, 0, len(c))); ok {
+				if h, g = r.findFixed(s, method, string(b), ctx); h != nil {
+					return string(b), h, g
+				}
+			}
+		}
+	}
+
+	return p, nil, nil
+}
+
+// findFixed returns the handler for method at the fixed path, or nil when
+// the path has none for the method; a path that would only respond with 405
+// Method Not Allowed isn't worth redirecting to.
+func (r *router) findFixed(s *snapshot, method, p string, ctx *Context) (h HandlerFunc, g *RouteGroup) {
+	ctx.allow = ""
+
+	if h, g = r.findInner(s, method, p, ctx); ctx.allow != "" && (method != OPTIONS || !r.lars.AutomaticOPTIONS) {
+		ctx.allow = ""
+		return nil, nil
+	}
+
+	return
+}
+
+// cleanPath returns the canonical form of p, as path.Clean, eliminating
+// repeated slashes and '.' and '..' elements, but keeping any trailing slash.
+func cleanPath(p string) string {
+	if p == "" || p[0] != '/' {
+		p = basePath + p
+	}
+
+	cp := path.Clean(p)
+
+	if p[len(p)-1] == '/' && cp != basePath {
+		cp += basePath
+	}
+
+	return cp
+}
+
+// toggleTrailingSlash adds the trailing slash to p, or removes it when present.
+func toggleTrailingSlash(p string) string {
+	if strings.HasSuffix(p, basePath) {
+		return p[:len(p)-1]
+	}
+	return p + basePath
+}
+
+// findCaseInsensitive walks the tree ignoring the case of static segments,
+// appending the path using the case the routes were registered with, while
+// keeping parameter values as requested, to b. It reports whether a node with
+// handlers was found.
+func (n *node) findCaseInsensitive(p string, b []byte) ([]byte, bool) {
+	switch n.kind {
+	case skind:
+		if len(p) < len(n.prefix) || !strings.EqualFold(p[:len(n.prefix)], n.prefix) {
+			return nil, false
+		}
+		b = append(b, n.prefix...)
+		p = p[len(n.prefix):]
+	case pkind:
+		i := strings.IndexByte(p, '/')
+		if i == -1 {
+			i = len(p)
+		}
+		if i == 0 || !n.constraint.match(p[:i]) {
+			return nil, false
+		}
+		b = append(b, p[:i]...)
+		p = p[i:]
+	case mkind:
+		return append(b, p...), n.methodHandler.allow[0] != ""
+	}
+
+	if p == "" && n.methodHandler.allow[0] != "" {
+		return b, true
+	}
+
+	// Search order static > param > match-any
+	for _, k := range []kind{skind, pkind, mkind} {
+		for _, c := range n.children {
+			if c.kind != k {
+				continue
+			}
+			if cb, ok := c.findCaseInsensitive(p, b); ok {
+				return cb, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// location returns the escaped fixed path, with the query string of u, for
+// use in the Location header. Segments of the original path are kept as
+// encoded by the client where they're unchanged.
+func location(u *url.URL, fixed string) string {
+	loc := (&url.URL{Path: fixed}).EscapedPath()
+
+	if u.RawPath != "" {
+		raw := u.EscapedPath()
+
+		if cleanPath(fixed) == fixed {
+			raw = cleanPath(raw)
+		}
+
+		if strings.HasSuffix(raw, basePath) != strings.HasSuffix(fixed, basePath) {
+			raw = toggleTrailingSlash(raw)
+		}
+
+		if s := reencode(strings.Split(raw, basePath), strings.Split(fixed, basePath)); s != "" {
+			loc = s
+		}
+	}
+
+	if u.RawQuery != "" {
+		loc += "?" + u.RawQuery
+	}
+
+	return loc
+}
+
+// reencode returns the fixed path using the raw segments where their decoded
+// value is unchanged, or "" when the segments don't align.
+func reencode(raw, fixed []string) string {
+	if len(raw) != len(fixed) {
+		return ""
+	}
+
+	for i, seg := range raw {
+		if v, err := url.PathUnescape(seg); err == nil && v == fixed[i] {
+			continue
+		}
+		raw[i] = (&url.URL{Path: fixed[i]}).EscapedPath()
+	}
+
+	return strings.Join(raw, basePath)
+}
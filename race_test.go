@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package lars
+
+func init() {
+	raceEnabled = true
+}
@@ -0,0 +1,186 @@
+package lars
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrRendererNotRegistered is returned by Context.Render when no Renderer has
+// been registered.
+var ErrRendererNotRegistered = errors.New("lars => renderer not registered")
+
+// maxBufferSize is the capacity above which buffers aren't returned to the
+// pool, so that a single large response doesn't keep its memory alive.
+const maxBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns a reset buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns the buffer to the pool, unless it has grown beyond
+// maxBufferSize.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// Encode encodes i using the Codec registered for the media type and sends it
+// with status code and the Codec's content type. Nothing is written when
+// encoding fails and the error is returned.
+func (c *Context) Encode(code int, mediaType string, i interface{}) error {
+	codec, ok := c.Response.lars.router.codec(mediaType)
+	if !ok {
+		return fmt.Errorf("lars => codec not registered for media type '%s'", mediaType)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := codec.Encode(buf, i); err != nil {
+		return err
+	}
+
+	return c.Blob(code, codec.ContentType(), buf.Bytes())
+}
+
+// JSON marshals i and sends it as an application/json response with status
+// code. Nothing is written when encoding fails and the error is returned.
+func (c *Context) JSON(code int, i interface{}) error {
+	return c.Encode(code, ApplicationJSON, i)
+}
+
+// JSONPretty marshals i with the provided indent and sends it as an
+// application/json response with status code.
+func (c *Context) JSONPretty(code int, i interface{}, indent string) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", indent)
+
+	if err := enc.Encode(i); err != nil {
+		return err
+	}
+
+	return c.Blob(code, ApplicationJSONCharsetUTF8, buf.Bytes())
+}
+
+// JSONP marshals i and sends it as an application/javascript response with
+// status code, wrapping the payload in the provided callback function. The
+// callback, usually taken from the query string, must be a JavaScript
+// identifier or a dotted path of them, eg. jQuery.cb_1, otherwise a 400 Bad
+// Request *HTTPError is returned.
+func (c *Context) JSONP(code int, callback string, i interface{}) error {
+	if !validCallback(callback) {
+		return NewHTTPError(http.StatusBadRequest, "invalid JSONP callback")
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	buf.WriteString(callback)
+	buf.WriteByte('(')
+
+	if err := json.NewEncoder(buf).Encode(i); err != nil {
+		return err
+	}
+
+	buf.WriteString(");")
+
+	return c.Blob(code, ApplicationJavaScriptCharsetUTF8, buf.Bytes())
+}
+
+// XML marshals i and sends it as an application/xml response with status
+// code, the standard XML header is prepended to the body.
+func (c *Context) XML(code int, i interface{}) error {
+	return c.Encode(code, ApplicationXML, i)
+}
+
+// Protobuf marshals the protobuf message m and sends it as an
+// application/protobuf response with status code, using the Codec registered
+// for application/protobuf.
+func (c *Context) Protobuf(code int, m interface{}) error {
+	return c.Encode(code, ApplicationProtobuf, m)
+}
+
+// Msgpack marshals i and sends it as an application/msgpack response with
+// status code, using the Codec registered for application/msgpack.
+func (c *Context) Msgpack(code int, i interface{}) error {
+	return c.Encode(code, ApplicationMsgpack, i)
+}
+
+// String sends a text/plain response with status code.
+func (c *Context) String(code int, s string) error {
+	c.writeContentType(code, TextPlainCharsetUTF8)
+	_, err := c.Response.WriteString(s)
+	return err
+}
+
+// HTML sends a text/html response with status code.
+func (c *Context) HTML(code int, html string) error {
+	c.writeContentType(code, TextHTMLCharsetUTF8)
+	_, err := c.Response.WriteString(html)
+	return err
+}
+
+// Blob sends b as the response body with the provided content type and
+// status code.
+func (c *Context) Blob(code int, contentType string, b []byte) error {
+	c.writeContentType(code, contentType)
+	_, err := c.Response.Write(b)
+	return err
+}
+
+// Stream copies r to the response with the provided content type and status
+// code.
+func (c *Context) Stream(code int, contentType string, r io.Reader) error {
+	c.writeContentType(code, contentType)
+	_, err := io.Copy(c.Response, r)
+	return err
+}
+
+func (c *Context) writeContentType(code int, contentType string) {
+	c.Response.Header().Set(ContentType, contentType)
+	c.Response.WriteHeader(code)
+}
+
+// Renderer is the interface used to render named templates, it is registered
+// on LARS using RegisterRenderer and invoked via Context.Render.
+type Renderer interface {
+	Render(w io.Writer, name string, data interface{}, c *Context) error
+}
+
+// Render renders the named template with data using the registered Renderer
+// and sends it as a text/html response with status code. Nothing is written
+// when rendering fails and the error is returned.
+func (c *Context) Render(code int, name string, data interface{}) error {
+	renderer := c.Response.lars.renderer
+	if renderer == nil {
+		return ErrRendererNotRegistered
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := renderer.Render(buf, name, data, c); err != nil {
+		return err
+	}
+
+	return c.Blob(code, TextHTMLCharsetUTF8, buf.Bytes())
+}
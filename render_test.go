@@ -0,0 +1,120 @@
+package lars
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+type renderUser struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func TestRender(t *testing.T) {
+	l := New()
+	u := renderUser{ID: 1, Name: "joeybloggs"}
+
+	l.Get("/json", func(c *Context) error {
+		return c.JSON(http.StatusOK, u)
+	})
+	l.Get("/jsonpretty", func(c *Context) error {
+		return c.JSONPretty(http.StatusOK, u, "  ")
+	})
+	l.Get("/jsonp", func(c *Context) error {
+		return c.JSONP(http.StatusOK, "callback", u)
+	})
+	l.Get("/xml", func(c *Context) error {
+		return c.XML(http.StatusCreated, u)
+	})
+	l.Get("/string", func(c *Context) error {
+		return c.String(http.StatusOK, "lars")
+	})
+	l.Get("/html", func(c *Context) error {
+		return c.HTML(http.StatusOK, "<h1>lars</h1>")
+	})
+	l.Get("/blob", func(c *Context) error {
+		return c.Blob(http.StatusOK, ApplicationProtobuf, []byte{0x08, 0x01})
+	})
+	l.Get("/stream", func(c *Context) error {
+		return c.Stream(http.StatusOK, TextPlain, strings.NewReader("streamed"))
+	})
+	l.Get("/badjson", func(c *Context) error {
+		return c.JSON(http.StatusOK, func() {})
+	})
+	l.Get("/badxml", func(c *Context) error {
+		return c.XML(http.StatusOK, make(chan int))
+	})
+	l.Get("/badjsonp", func(c *Context) error {
+		return c.JSONP(http.StatusOK, "callback", func() {})
+	})
+	l.Get("/jsonp/callback", func(c *Context) error {
+		return c.JSONP(http.StatusOK, c.Request.URL.Query().Get("callback"), u)
+	})
+	l.Get("/badjsonpretty", func(c *Context) error {
+		return c.JSONPretty(http.StatusOK, func() {}, "  ")
+	})
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/json", http.StatusOK, ApplicationJSONCharsetUTF8, "{\"id\":1,\"name\":\"joeybloggs\"}\n"},
+		{"/jsonpretty", http.StatusOK, ApplicationJSONCharsetUTF8, "{\n  \"id\": 1,\n  \"name\": \"joeybloggs\"\n}\n"},
+		{"/jsonp", http.StatusOK, ApplicationJavaScriptCharsetUTF8, "callback({\"id\":1,\"name\":\"joeybloggs\"}\n);"},
+		{"/xml", http.StatusCreated, ApplicationXMLCharsetUTF8, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<renderUser><id>1</id><name>joeybloggs</name></renderUser>"},
+		{"/string", http.StatusOK, TextPlainCharsetUTF8, "lars"},
+		{"/html", http.StatusOK, TextHTMLCharsetUTF8, "<h1>lars</h1>"},
+		{"/blob", http.StatusOK, ApplicationProtobuf, "\x08\x01"},
+		{"/stream", http.StatusOK, TextPlain, "streamed"},
+		{"/badjson", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+		{"/badxml", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+		{"/badjsonp", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+		{"/jsonp/callback?callback=jQuery.cb_1$", http.StatusOK, ApplicationJavaScriptCharsetUTF8, "jQuery.cb_1$({\"id\":1,\"name\":\"joeybloggs\"}\n);"},
+		{"/jsonp/callback?callback=alert(1)//", http.StatusBadRequest, TextPlainCharsetUTF8, "invalid JSONP callback\n"},
+		{"/jsonp/callback?callback=1cb", http.StatusBadRequest, TextPlainCharsetUTF8, "invalid JSONP callback\n"},
+		{"/jsonp/callback?callback=cb..fn", http.StatusBadRequest, TextPlainCharsetUTF8, "invalid JSONP callback\n"},
+		{"/jsonp/callback", http.StatusBadRequest, TextPlainCharsetUTF8, "invalid JSONP callback\n"},
+		{"/badjsonpretty", http.StatusInternalServerError, TextPlainCharsetUTF8, "Internal Server Error\n"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(GET, tt.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, tt.code)
+		Equal(t, w.Header().Get(ContentType), tt.contentType)
+		Equal(t, w.Body.String(), tt.body)
+	}
+}
+
+func TestRenderBufferPool(t *testing.T) {
+	buf := getBuffer()
+	buf.Grow(maxBufferSize + 1)
+	putBuffer(buf)
+
+	// oversized buffers are dropped rather than pooled
+	for i := 0; i < 10; i++ {
+		Equal(t, getBuffer() == buf, false)
+	}
+}
+
+func TestRenderResponseSize(t *testing.T) {
+	c := newTestContext(GET, "/", "", "")
+
+	Equal(t, c.String(http.StatusAccepted, "lars"), nil)
+	Equal(t, c.Response.Status(), http.StatusAccepted)
+	Equal(t, c.Response.Size(), int64(4))
+
+	c = newTestContext(GET, "/", "", "")
+	err := c.JSON(http.StatusOK, errors.New)
+	NotEqual(t, err, nil)
+	Equal(t, c.Response.Committed(), false)
+	Equal(t, c.Response.Size(), int64(0))
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 )
 
 // Response struct contains a context *Response
@@ -16,6 +17,11 @@ type Response struct {
 	size      int64
 	committed bool
 	lars      *LARS
+
+	// discardBody is set when serving a HEAD request using a GET handler, the
+	// header is then only written once the handler completes so that the
+	// Content-Length can be set from the discarded body's size.
+	discardBody bool
 }
 
 // Header returns the header map that will be sent by
@@ -39,8 +45,11 @@ func (r *Response) WriteHeader(code int) {
 		return
 	}
 	r.status = code
-	r.ResponseWriter.WriteHeader(code)
 	r.committed = true
+	if r.discardBody {
+		return
+	}
+	r.ResponseWriter.WriteHeader(code)
 }
 
 // Write writes the data to the connection as part of an HTTP reply.
@@ -49,6 +58,9 @@ func (r *Response) WriteHeader(code int) {
 // Content-Type line, Write adds a Content-Type set to the result of passing
 // the initial 512 bytes of written data to DetectContentType.
 func (r *Response) Write(b []byte) (n int, err error) {
+	if r.discardBody {
+		return r.discard(len(b))
+	}
 	n, err = r.ResponseWriter.Write(b)
 	r.size += int64(n)
 	return n, err
@@ -56,13 +68,41 @@ func (r *Response) Write(b []byte) (n int, err error) {
 
 // WriteString write string to ResponseWriter
 func (r *Response) WriteString(s string) (n int, err error) {
+	if r.discardBody {
+		return r.discard(len(s))
+	}
 	n, err = io.WriteString(r.ResponseWriter, s)
 	r.size += int64(n)
 	return
 }
 
-// Flush wraps response writer's Flush function.
+// discard records n bytes as written without writing them.
+func (r *Response) discard(n int) (int, error) {
+	if !r.committed {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.size += int64(n)
+	return n, nil
+}
+
+// writeDeferredHeader writes the header withheld while discarding the body,
+// setting the Content-Length if the handler did not.
+func (r *Response) writeDeferredHeader() {
+	if !r.discardBody {
+		return
+	}
+	if r.Header().Get(ContentLength) == "" {
+		r.Header().Set(ContentLength, strconv.FormatInt(r.size, 10))
+	}
+	r.ResponseWriter.WriteHeader(r.status)
+}
+
+// Flush wraps response writer's Flush function. It does nothing while the
+// body is being discarded, as flushing would send the withheld header.
 func (r *Response) Flush() {
+	if r.discardBody {
+		return
+	}
 	r.ResponseWriter.(http.Flusher).Flush()
 }
 
@@ -97,5 +137,6 @@ func (r *Response) reset(w http.ResponseWriter, l *LARS) {
 	r.size = 0
 	r.status = http.StatusOK
 	r.committed = false
+	r.discardBody = false
 	r.lars = l
 }
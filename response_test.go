@@ -73,4 +73,31 @@ func TestResponse(t *testing.T) {
 
 	// reset
 	r.reset(httptest.NewRecorder(), nil)
+	Equal(t, r.Committed(), false)
+	Equal(t, r.Size(), int64(0))
+}
+
+func TestResponseDiscardBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := &Response{ResponseWriter: w}
+	r.reset(w, nil)
+	r.discardBody = true
+
+	n, err := r.Write([]byte("lars"))
+	Equal(t, err, nil)
+	Equal(t, n, 4)
+	Equal(t, r.Committed(), true)
+
+	n, err = r.WriteString("lars")
+	Equal(t, err, nil)
+	Equal(t, n, 4)
+	Equal(t, r.Size(), int64(8))
+
+	// header withheld until the handler completes
+	Equal(t, w.Header().Get(ContentLength), "")
+
+	r.writeDeferredHeader()
+	Equal(t, w.Code, http.StatusOK)
+	Equal(t, w.Header().Get(ContentLength), "8")
+	Equal(t, w.Body.Len(), 0)
 }
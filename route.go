@@ -0,0 +1,157 @@
+package lars
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Route is returned when registering a route and allows naming it, so that
+// its URI may be generated using URIFor.
+type Route struct {
+	router *router
+	routes []*RouteInfo
+}
+
+// NamedParams are the parameter values, by name, used to generate a URI with
+// URIFor; the match-any segment is named "_*".
+type NamedParams map[string]interface{}
+
+func (g *RouteGroup) newRoute(routes ...*RouteInfo) *Route {
+	return &Route{router: g.lars.router, routes: routes}
+}
+
+// Name names the route, panicking if the name is already in use by a route
+// with a different path.
+func (r *Route) Name(name string) *Route {
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+
+	for _, rt := range r.routes {
+		path := rt.Path
+
+		if p, ok := r.router.names[name]; ok && p != path {
+			panic(fmt.Sprintf("lars => route name '%s' is already in use by route '%s'", name, p))
+		}
+
+		r.router.names[name] = path
+		rt.Name = name
+	}
+
+	return r
+}
+
+// URIFor generates a URI for the named route.
+//
+// Parameters may be passed positionally, in the order they appear in the
+// route's path, or as a single NamedParams. Values are URL escaped and a
+// url.Values passed as the last argument is appended as the query string.
+// Optional parameters may be omitted, omitting their segment, and named
+// match-any parameters, eg. *filepath, are passed by name. An error is
+// returned when the route doesn't exist or a parameter is missing.
+func (l *LARS) URIFor(name string, params ...interface{}) (string, error) {
+	l.router.mu.Lock()
+	path, ok := l.router.names[name]
+	l.router.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("lars => route '%s' not found", name)
+	}
+
+	var query url.Values
+
+	if n := len(params); n > 0 {
+		if q, ok := params[n-1].(url.Values); ok {
+			query = q
+			params = params[:n-1]
+		}
+	}
+
+	var named NamedParams
+
+	if len(params) == 1 {
+		named, _ = params[0].(NamedParams)
+	}
+
+	uri := new(bytes.Buffer)
+	n := 0
+
+	value := func(pname string) (string, error) {
+		if named != nil {
+			if v, ok := named[pname]; ok {
+				return fmt.Sprint(v), nil
+			}
+		} else if n < len(params) {
+			n++
+			return fmt.Sprint(params[n-1]), nil
+		}
+		return "", fmt.Errorf("lars => missing parameter '%s' for route '%s'", pname, name)
+	}
+
+	for i, k := 0, len(path); i < k; i++ {
+		switch path[i] {
+		case ':':
+			j := i + 1
+			for ; i < k && path[i] != '/' && path[i] != '<' && path[i] != '?'; i++ {
+			}
+
+			pname := path[j:i]
+
+			if i < k && path[i] == '<' {
+				i = constraintEnd(path, i) + 1
+			}
+
+			optional := i < k && path[i] == '?'
+			if optional {
+				i++
+			}
+
+			v, err := value(pname)
+			if err != nil {
+				if !optional {
+					return "", err
+				}
+
+				// the optional parameter's segment, and those following it,
+				// are omitted
+				if uri.Len() > 1 {
+					uri.Truncate(uri.Len() - 1)
+				}
+				i = k
+				break
+			}
+
+			uri.WriteString(url.PathEscape(v))
+
+			if i < k {
+				uri.WriteByte(path[i])
+			}
+
+		case '*':
+			v, err := value(catchAllName(path[i+1:]))
+			if err != nil {
+				return "", err
+			}
+			i = k
+
+			// match-any values may span segments, so only escape each segment
+			segments := strings.Split(v, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+
+			uri.WriteString(strings.Join(segments, "/"))
+
+		default:
+			uri.WriteByte(path[i])
+		}
+	}
+
+	if len(query) > 0 {
+		uri.WriteByte('?')
+		uri.WriteString(query.Encode())
+	}
+
+	return uri.String(), nil
+}
@@ -0,0 +1,121 @@
+package lars
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func TestURIFor(t *testing.T) {
+	l := New()
+	h := func(*Context) {}
+
+	l.Get("/users/:id<int>", h).Name("user.show")
+	l.Get("/accounts/:uid/files/:fid", h).Name("user.file")
+	l.Get("/static/*", h).Name("static")
+	l.Any("/any/:id", h).Name("any")
+
+	g := l.Group("/admin")
+	g.Match([]string{GET, POST}, "/settings", h).Name("admin.settings")
+
+	uri, err := l.URIFor("user.show", 1)
+	Equal(t, err, nil)
+	Equal(t, uri, "/users/1")
+
+	uri, err = l.URIFor("user.file", "joey bloggs", "a/b")
+	Equal(t, err, nil)
+	Equal(t, uri, "/accounts/joey%20bloggs/files/a%2Fb")
+
+	uri, err = l.URIFor("user.file", NamedParams{"fid": 2, "uid": 1})
+	Equal(t, err, nil)
+	Equal(t, uri, "/accounts/1/files/2")
+
+	uri, err = l.URIFor("user.file", 1, 2, url.Values{"sort": []string{"asc"}, "q": []string{"a&b"}})
+	Equal(t, err, nil)
+	Equal(t, uri, "/accounts/1/files/2?q=a%26b&sort=asc")
+
+	uri, err = l.URIFor("static", "css/site main.css")
+	Equal(t, err, nil)
+	Equal(t, uri, "/static/css/site%20main.css")
+
+	uri, err = l.URIFor("static", NamedParams{"_*": "js/app.js"})
+	Equal(t, err, nil)
+	Equal(t, uri, "/static/js/app.js")
+
+	uri, err = l.URIFor("any", 5)
+	Equal(t, err, nil)
+	Equal(t, uri, "/any/5")
+
+	uri, err = l.URIFor("admin.settings")
+	Equal(t, err, nil)
+	Equal(t, uri, "/admin/settings")
+
+	_, err = l.URIFor("user.file", 1)
+	Equal(t, err.Error(), "lars => missing parameter 'fid' for route 'user.file'")
+
+	_, err = l.URIFor("user.file", NamedParams{"uid": 1})
+	Equal(t, err.Error(), "lars => missing parameter 'fid' for route 'user.file'")
+
+	_, err = l.URIFor("static")
+	Equal(t, err.Error(), "lars => missing parameter '_*' for route 'static'")
+
+	_, err = l.URIFor("unknown")
+	Equal(t, err.Error(), "lars => route 'unknown' not found")
+
+	PanicMatches(t, func() { l.Post("/users", h).Name("user.show") }, "lars => route name 'user.show' is already in use by route '/users/:id<int>'")
+
+	// names are reported by Routes
+	l.Post("/users/:id<int>", h).Name("user.show")
+
+	for _, r := range l.Routes() {
+		if r.Path == "/users/:id<int>" {
+			Equal(t, r.Name, "user.show")
+		}
+	}
+
+	code, _ := request(POST, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+}
+
+func TestURIMatchAny(t *testing.T) {
+	l := New()
+	static := func(*Context) {}
+	l.Get("/static/*", static)
+
+	Equal(t, l.URI(static, "css/main.css"), "/static/css/main.css")
+	Equal(t, l.URI(static), "/static/*")
+}
+
+func TestURIOptionalAndNamedMatchAny(t *testing.T) {
+	l := New()
+	archive := func(*Context) {}
+	files := func(*Context) {}
+
+	l.Get("/archive/:year<int>/:month?/:day?", archive).Name("archive")
+	l.Get("/files/*filepath", files).Name("files")
+
+	uri, err := l.URIFor("archive", 2020, 5, 1)
+	Equal(t, err, nil)
+	Equal(t, uri, "/archive/2020/5/1")
+
+	uri, err = l.URIFor("archive", 2020)
+	Equal(t, err, nil)
+	Equal(t, uri, "/archive/2020")
+
+	uri, err = l.URIFor("archive", NamedParams{"year": 2020, "month": 5})
+	Equal(t, err, nil)
+	Equal(t, uri, "/archive/2020/5")
+
+	_, err = l.URIFor("archive")
+	NotEqual(t, err, nil)
+
+	uri, err = l.URIFor("files", NamedParams{"filepath": "css/main file.css"})
+	Equal(t, err, nil)
+	Equal(t, uri, "/files/css/main%20file.css")
+
+	Equal(t, l.URI(archive, 2020, 5), "/archive/2020/5")
+	Equal(t, l.URI(archive, 2020), "/archive/2020")
+	Equal(t, l.URI(files, "css/main.css"), "/files/css/main.css")
+}
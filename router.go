@@ -1,14 +1,54 @@
 package lars
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type router struct {
-	tree   *node
-	routes []route
+	// tree, hosts and the groups below are modified during registration,
+	// while find uses the copies last published to live and liveHosts.
+	tree      *node
+	live      atomic.Value
+	liveHosts atomic.Value
+
+	// mu serializes registration, serving is set once requests are being
+	// served, after which the trees are modified copy-on-write, and maxParams
+	// mirrors LARS.maxParam for reading while serving.
+	mu        sync.Mutex
+	serving   int32
+	maxParams int32
+
+	routes []*RouteInfo
+	names  map[string]string
+
+	// keys indexes routes by host and the key of each of the paths they
+	// match, see routeKey, for detecting conflicting routes
+	keys map[string][]*RouteInfo
+
+	hosts  []*hostRouter
+	chains []*chain
 	lars   *LARS
+
+	// codecs holds the codecs registered by media type
+	codecs atomic.Value
+
+	// groups with custom not found and method not allowed handlers, longest
+	// prefix first, replaced rather than modified once published
+	notFound         []*RouteGroup
+	methodNotAllowed []*RouteGroup
+}
+
+// snapshot is the tree and groups used to serve requests, published by the
+// router each time they change.
+type snapshot struct {
+	tree             *node
+	notFound         []*RouteGroup
+	methodNotAllowed []*RouteGroup
 }
 
 type node struct {
@@ -20,7 +60,8 @@ type node struct {
 	ppath         string
 	pnames        []string
 	methodHandler *methodHandler
-	lars          *LARS
+	group         *RouteGroup
+	constraint    *paramConstraint
 }
 
 type kind uint8
@@ -35,6 +76,15 @@ type methodHandler struct {
 	post    HandlerFunc
 	put     HandlerFunc
 	trace   HandlerFunc
+
+	// extension methods, such as WebDAV's PROPFIND, and the handler registered
+	// via Any that is used for extension methods without their own handler.
+	custom map[string]HandlerFunc
+	any    HandlerFunc
+
+	// allow holds the precomputed Allow header values for the registered
+	// methods, indexed by the automatic method flags in effect.
+	allow [4]string
 }
 
 const (
@@ -43,54 +93,597 @@ const (
 	mkind
 )
 
+// automatic method flags used to index methodHandler.allow
+const (
+	autoOptions = 1 << iota
+	autoHead
+)
+
 // newRouter returns a new *router instance
 func newRouter(l *LARS) *router {
-	return &router{
+	r := &router{
 		tree: &node{
 			methodHandler: new(methodHandler),
 		},
-		routes: []route{},
+		routes: []*RouteInfo{},
+		names:  make(map[string]string),
+		keys:   make(map[string][]*RouteInfo),
 		lars:   l,
 	}
+
+	r.publish()
+	r.liveHosts.Store([]*hostRouter(nil))
+
+	return r
 }
 
-func (r *router) add(method, path string, h HandlerFunc, l *LARS) {
-	ppath := path        // Pristine path
-	pnames := []string{} // Param names
+// snapshot returns the snapshot used to serve requests.
+func (r *router) snapshot() *snapshot {
+	return r.live.Load().(*snapshot)
+}
 
-	for i, k := 0, len(path); i < k; i++ {
-		if path[i] == ':' {
-			j := i + 1
+// publish makes the router's tree and groups those used to serve requests, it
+// must be called holding mu once they're no longer modified in place.
+func (r *router) publish() {
+	r.live.Store(&snapshot{
+		tree:             r.tree,
+		notFound:         r.notFound,
+		methodNotAllowed: r.methodNotAllowed,
+	})
+}
+
+// serve marks the router as serving requests, waiting for any registration in
+// progress, which may be modifying the trees in place, to complete.
+func (r *router) serve() {
+	r.mu.Lock()
+	atomic.StoreInt32(&r.serving, 1)
+	r.mu.Unlock()
+}
 
-			r.insert(method, path[:i], nil, skind, "", nil, l)
-			for ; i < k && path[i] != '/'; i++ {
+// update runs fn, which modifies the trees of routers, and must be called
+// holding mu. Once requests are being served fn modifies copies of the trees
+// which are then swapped in atomically, so that find never takes a lock nor
+// observes a partially modified tree.
+func (r *router) update(routers []*router, fn func()) {
+	if atomic.LoadInt32(&r.serving) == 0 {
+		fn()
+		return
+	}
+
+	mhs := make(map[*methodHandler]*methodHandler)
+
+	for _, rt := range routers {
+		rt.tree = rt.tree.clone(nil, mhs)
+	}
+
+	for _, rt := range r.routes {
+		for i, mh := range rt.mhs {
+			if c, ok := mhs[mh]; ok {
+				rt.mhs[i] = c
 			}
+		}
+	}
+
+	fn()
+
+	for _, rt := range routers {
+		rt.publish()
+	}
+}
+
+// routers returns the router along with those of every host pattern.
+func (r *router) routers() []*router {
+	routers := []*router{r}
+	for _, hr := range r.hosts {
+		routers = append(routers, hr.router)
+	}
+	return routers
+}
+
+// clone returns a deep copy of the tree rooted at n, recording the copy of
+// each methodHandler in mhs.
+func (n *node) clone(parent *node, mhs map[*methodHandler]*methodHandler) *node {
+	c := *n
+	c.parent = parent
+
+	mh := *n.methodHandler
+	if n.methodHandler.custom != nil {
+		mh.custom = make(map[string]HandlerFunc, len(n.methodHandler.custom))
+		for m, h := range n.methodHandler.custom {
+			mh.custom[m] = h
+		}
+	}
+	c.methodHandler = &mh
+	mhs[n.methodHandler] = &mh
+
+	c.children = make(children, len(n.children))
+	for i, child := range n.children {
+		c.children[i] = child.clone(&c, mhs)
+	}
+
+	return &c
+}
+
+// addRoute adds the route after ensuring it doesn't conflict with an existing
+// route, which would otherwise silently replace its handler or parameter names,
+// and returns its details.
+func (r *router) addRoute(method, path, name string, h HandlerFunc, g *RouteGroup) *RouteInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
+	rt := &RouteInfo{
+		Method:  method,
+		Path:    path,
+		Handler: name,
+		Params:  paramNames(path),
+		group:   g,
+	}
+
+	// routes registered on a host pattern have their own tree
+	tree := g.tree()
+
+	if g.host != nil {
+		rt.Host = g.host.pattern
+	}
+
+	// parsing validates the path, and compiles its constraints, before the
+	// tree is modified
+	paths := parsePath(path)
+
+	existing, mismatch := r.conflictingRoute(rt)
+
+	if existing != nil && !r.lars.AllowRouteOverride {
+		panic(conflictError(rt, existing))
+	}
+
+	if mismatch != nil {
+		panic(conflictError(rt, mismatch))
+	}
+
+	// the handler is stored wrapped by the group's middleware, the original
+	// being kept so it can be rewrapped should the middleware change
+	rt.handler = h
+
+	r.update([]*router{tree}, func() {
+		if existing != nil {
+			for _, mh := range existing.mhs {
+				tree.tree.remove(method, mh)
+			}
+		}
+
+		rt.mhs = rt.mhs[:0]
+		for _, n := range tree.addPaths(method, paths, path, g.chain.then(h), g) {
+			rt.mhs = append(rt.mhs, n.methodHandler)
+		}
+	})
+
+	if existing != nil {
+		r.unindex(existing)
+		*existing = *rt
+		r.index(existing)
+		return existing
+	}
+
+	r.routes = append(r.routes, rt)
+	r.index(rt)
+
+	return rt
+}
+
+// conflictingRoute returns the existing route served by the same node as rt
+// for its method, which may be overridden, and any existing route for another
+// method served by the same node using different parameter names, which may
+// not as the node has a single set of names.
+func (r *router) conflictingRoute(rt *RouteInfo) (existing, mismatch *RouteInfo) {
+	for _, p := range expandOptional(rt.Path) {
+		key := routeKey(p)
+
+		for _, other := range r.keys[rt.Host+key] {
+			switch {
+			case other.Method == rt.Method:
+				existing = other
+			case mismatch == nil && !equalNames(paramNames(p), keyParams(other.Path, key)):
+				mismatch = other
+			}
+		}
+	}
+
+	return
+}
+
+// conflictError returns the message of the panic for registering rt.
+func conflictError(rt, existing *RouteInfo) string {
+	return fmt.Sprintf("lars => route '%s %s%s' (%s) conflicts with existing route '%s %s%s' (%s)",
+		rt.Method, rt.Host, rt.Path, rt.Handler, existing.Method, existing.Host, existing.Path, existing.Handler)
+}
+
+// keyParams returns the parameter names of the path matched by path whose key
+// is key.
+func keyParams(path, key string) []string {
+	for _, p := range expandOptional(path) {
+		if routeKey(p) == key {
+			return paramNames(p)
+		}
+	}
+	return nil
+}
+
+// equalNames reports whether a and b hold the same names in the same order.
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// index adds the route to keys.
+func (r *router) index(rt *RouteInfo) {
+	for _, key := range routeKeys(rt.Path) {
+		r.keys[rt.Host+key] = append(r.keys[rt.Host+key], rt)
+	}
+}
+
+// unindex removes the route from keys.
+func (r *router) unindex(rt *RouteInfo) {
+	for _, key := range routeKeys(rt.Path) {
+		routes := r.keys[rt.Host+key]
+		keep := make([]*RouteInfo, 0, len(routes))
+
+		for _, other := range routes {
+			if other != rt {
+				keep = append(keep, other)
+			}
+		}
+
+		if len(keep) == 0 {
+			delete(r.keys, rt.Host+key)
+			continue
+		}
+
+		r.keys[rt.Host+key] = keep
+	}
+}
+
+// removeRoute removes the route for method and path, including routes whose
+// path differs only by parameter names, reporting whether it existed.
+func (r *router) removeRoute(method, path string, g *RouteGroup) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var host string
+
+	if g.host != nil {
+		host = g.host.pattern
+	}
+
+	var rt *RouteInfo
+
+	for _, key := range routeKeys(path) {
+		for _, other := range r.keys[host+key] {
+			if other.Method == method {
+				rt = other
+			}
+		}
+	}
+
+	if rt == nil {
+		return false
+	}
+
+	tree := g.tree()
+
+	r.update([]*router{tree}, func() {
+		for _, mh := range rt.mhs {
+			tree.tree.remove(method, mh)
+		}
+	})
+
+	r.unindex(rt)
+
+	for i, other := range r.routes {
+		if other == rt {
+			r.routes = append(r.routes[:i], r.routes[i+1:]...)
+			break
+		}
+	}
+
+	if rt.Name != "" {
+		delete(r.names, rt.Name)
+
+		for _, other := range r.routes {
+			if other.Name == rt.Name {
+				r.names[rt.Name] = other.Path
+			}
+		}
+	}
+
+	return true
+}
+
+// remove removes the method's handler from the node using mh, pruning nodes
+// left without handlers or children.
+func (n *node) remove(method string, mh *methodHandler) bool {
+	if n.methodHandler != mh {
+		for _, c := range n.children {
+			if c.remove(method, mh) {
+				return true
+			}
+		}
+		return false
+	}
+
+	mh.setHandler(method, nil)
+
+	for ; n.parent != nil && len(n.children) == 0 && mh.allow[0] == "" && mh.any == nil; n, mh = n.parent, n.parent.methodHandler {
+		siblings := make(children, 0, len(n.parent.children)-1)
+		for _, c := range n.parent.children {
+			if c != n {
+				siblings = append(siblings, c)
+			}
+		}
+		n.parent.children = siblings
+	}
+
+	return true
+}
+
+// paramNames returns the names of the parameters within path.
+func paramNames(path string) []string {
+	pnames := []string{}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case ':':
+			j := i + 1
+			for ; i < len(path) && path[i] != '/' && path[i] != '<' && path[i] != '?'; i++ {
+			}
 			pnames = append(pnames, path[j:i])
-			path = path[:j] + path[i:]
-			i, k = j, len(path)
+			if i < len(path) && path[i] == '<' {
+				i = constraintEnd(path, i)
+			}
+		case '*':
+			pnames = append(pnames, catchAllName(path[i+1:]))
+			return pnames
+		}
+	}
 
-			if i == k {
-				r.insert(method, path[:i], h, pkind, ppath, pnames, l)
-				return
+	return pnames
+}
+
+// catchAllName returns the name of the match-any parameter, "_*" when it's
+// unnamed.
+func catchAllName(name string) string {
+	if name == "" {
+		return "_*"
+	}
+	return name
+}
+
+// routeKey returns the path with parameter names removed, two paths with the
+// same key are served by the same node. Constraints are kept as parameters
+// with different constraints are served by different nodes.
+func routeKey(path string) string {
+	key := make([]byte, 0, len(path))
+
+	for i := 0; i < len(path); i++ {
+		key = append(key, path[i])
+
+		if path[i] == '*' {
+			break
+		}
+
+		if path[i] != ':' {
+			continue
+		}
+
+		for i++; i < len(path) && path[i] != '/'; i++ {
+			if path[i] == '<' {
+				e := constraintEnd(path, i)
+				key = append(key, path[i:e+1]...)
+				i = e
+			}
+		}
+
+		if i < len(path) {
+			key = append(key, path[i])
+		}
+	}
+
+	return string(key)
+}
+
+// routeKeys returns the keys of each of the paths matched by path.
+func routeKeys(path string) []string {
+	paths := expandOptional(path)
+	for i, p := range paths {
+		paths[i] = routeKey(p)
+	}
+	return paths
+}
+
+// expandOptional returns the paths matched by path, whose trailing parameters
+// may be optional; eg. /archive/:year/:month? matches /archive/:year/:month
+// and /archive/:year. The longest path is first.
+func expandOptional(path string) []string {
+	var (
+		b    = make([]byte, 0, len(path))
+		cuts []int // lengths of b before each optional segment
+	)
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != ':' {
+			// only further optional parameters may follow one
+			if len(cuts) > 0 && (path[i] != '/' || i+1 == len(path) || path[i+1] != ':') {
+				panic("lars => optional parameters must be the last segments of path '" + path + "'")
+			}
+			b = append(b, path[i])
+			continue
+		}
+
+		j := i + 1
+		for ; j < len(path) && path[j] != '/' && path[j] != '<' && path[j] != '?'; j++ {
+		}
+		if j < len(path) && path[j] == '<' {
+			j = constraintEnd(path, j) + 1
+		}
+
+		switch {
+		case j < len(path) && path[j] == '?':
+			if i == 0 || path[i-1] != '/' || (j+1 < len(path) && path[j+1] != '/') {
+				panic("lars => optional parameter must be a whole segment of path '" + path + "'")
+			}
+			cuts = append(cuts, len(b)-1)
+			b = append(b, path[i:j]...)
+			j++
+		case len(cuts) > 0:
+			panic("lars => optional parameters must be the last segments of path '" + path + "'")
+		default:
+			b = append(b, path[i:j]...)
+		}
+
+		i = j - 1
+	}
+
+	paths := []string{string(b)}
+
+	for i := len(cuts) - 1; i >= 0; i-- {
+		p := string(b[:cuts[i]])
+		if p == "" {
+			p = basePath
+		}
+		paths = append(paths, p)
+	}
+
+	return paths
+}
+
+// routePath is one of the paths matched by a route with its parameter names
+// and constraints removed, eg. /users/:/files for /users/:id<int>/files, along
+// with the names and compiled constraints of each parameter.
+type routePath struct {
+	path        string
+	pnames      []string
+	constraints []*paramConstraint
+}
+
+// parsePath returns the paths matched by path, one for each of its optional
+// parameters, panicking when it's invalid.
+func parsePath(path string) []routePath {
+	paths := expandOptional(path)
+	rps := make([]routePath, len(paths))
+
+	for i, p := range paths {
+		rps[i] = parseRoutePath(p, path)
+	}
+
+	return rps
+}
+
+// parseRoutePath parses path, which has no optional parameters, as matched by
+// the route registered with ppath.
+func parseRoutePath(path, ppath string) routePath {
+	rp := routePath{pnames: []string{}}
+	b := make([]byte, 0, len(path))
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case ':':
+			j := i + 1
+			for i = j; i < len(path) && path[i] != '/' && path[i] != '<'; i++ {
+			}
+
+			rp.pnames = append(rp.pnames, path[j:i])
+
+			// Constraint, stripped from the path once compiled
+			var pc *paramConstraint
+
+			if i < len(path) && path[i] == '<' {
+				e := constraintEnd(path, i)
+				pc = newParamConstraint(path[i+1 : e])
+				i = e + 1
 			}
-			r.insert(method, path[:i], nil, pkind, ppath, pnames, l)
-		} else if path[i] == '*' {
-			r.insert(method, path[:i], nil, skind, "", nil, l)
-			pnames = append(pnames, "_*")
-			r.insert(method, path[:i+1], h, mkind, ppath, pnames, l)
-			return
+
+			rp.constraints = append(rp.constraints, pc)
+			b = append(b, ':')
+			i--
+		case '*':
+			name := path[i+1:]
+			if strings.ContainsAny(name, "/:*") {
+				panic("lars => match-any must be the last segment of path '" + ppath + "'")
+			}
+			rp.pnames = append(rp.pnames, catchAllName(name))
+			rp.path = string(append(b, '*'))
+			return rp
+		default:
+			b = append(b, path[i])
+		}
+	}
+
+	rp.path = string(b)
+
+	return rp
+}
+
+// add inserts the handler into the tree for l's root group, returning the
+// nodes it's stored on; one for each of the paths matched when path has
+// optional parameters.
+func (r *router) add(method, path string, h HandlerFunc, l *LARS) []*node {
+	return r.addPaths(method, parsePath(path), path, h, &l.RouteGroup)
+}
+
+// addPaths inserts the handler into the tree at each of the parsed paths of the
+// route registered with ppath, returning the nodes it's stored on.
+func (r *router) addPaths(method string, paths []routePath, ppath string, h HandlerFunc, g *RouteGroup) []*node {
+	nodes := make([]*node, len(paths))
+
+	for i, rp := range paths {
+		nodes[i] = r.addPath(method, rp, ppath, h, g)
+	}
+
+	return nodes
+}
+
+// addPath inserts the handler into the tree at the path, recording ppath as
+// the path it was registered with.
+func (r *router) addPath(method string, rp routePath, ppath string, h HandlerFunc, g *RouteGroup) *node {
+	path, pcs := rp.path, rp.constraints
+
+	for i, k := 0, len(path); i < k; i++ {
+		switch path[i] {
+		case ':':
+			r.insert(method, path[:i], nil, skind, "", nil, pcs, g)
+
+			pnames := rp.pnames[:strings.Count(path[:i+1], ":")]
+
+			if i+1 == k {
+				return r.insert(method, path, h, pkind, ppath, pnames, pcs, g)
+			}
+			r.insert(method, path[:i+1], nil, pkind, ppath, pnames, pcs, g)
+		case '*':
+			r.insert(method, path[:i], nil, skind, "", nil, pcs, g)
+			return r.insert(method, path, h, mkind, ppath, rp.pnames, pcs, g)
 		}
 	}
 
-	r.insert(method, path, h, skind, ppath, pnames, l)
+	return r.insert(method, path, h, skind, ppath, rp.pnames, pcs, g)
 }
 
-func (r *router) insert(method, path string, h HandlerFunc, t kind, ppath string, pnames []string, l *LARS) {
+// insert adds the handler to the tree, returning the node at path. The
+// constraints are those of each parameter of path, used to find or create the
+// node of each.
+func (r *router) insert(method, path string, h HandlerFunc, t kind, ppath string, pnames []string, pcs []*paramConstraint, g *RouteGroup) *node {
 	// Adjust max param
 	j := len(pnames)
-	if *l.maxParam < j {
-		*l.maxParam = j
+	if *g.lars.maxParam < j {
+		*g.lars.maxParam = j
+	}
+	if int(atomic.LoadInt32(&g.lars.router.maxParams)) < j {
+		atomic.StoreInt32(&g.lars.router.maxParams, int32(j))
 	}
 
 	cn := r.tree // Current node as root
@@ -121,11 +714,12 @@ func (r *router) insert(method, path string, h HandlerFunc, t kind, ppath string
 				cn.addHandler(method, h)
 				cn.ppath = ppath
 				cn.pnames = pnames
-				cn.lars = l
+				cn.group = g
 			}
 		} else if j < pl {
 			// Split node
-			n := newNode(cn.kind, cn.prefix[j:], cn, cn.children, cn.methodHandler, cn.ppath, cn.pnames, cn.lars)
+			n := newNode(cn.kind, cn.prefix[j:], cn, cn.children, cn.methodHandler, cn.ppath, cn.pnames, cn.group)
+			n.constraint = cn.constraint
 
 			// Reset parent node
 			cn.kind = skind
@@ -135,7 +729,8 @@ func (r *router) insert(method, path string, h HandlerFunc, t kind, ppath string
 			cn.methodHandler = new(methodHandler)
 			cn.ppath = ""
 			cn.pnames = nil
-			cn.lars = nil
+			cn.group = nil
+			cn.constraint = nil
 
 			cn.addChild(n)
 
@@ -145,39 +740,46 @@ func (r *router) insert(method, path string, h HandlerFunc, t kind, ppath string
 				cn.addHandler(method, h)
 				cn.ppath = ppath
 				cn.pnames = pnames
-				cn.lars = l
+				cn.group = g
 			} else {
 				// Create child node
-				n = newNode(t, search[j:], cn, nil, new(methodHandler), ppath, pnames, l)
+				n = newNode(t, search[j:], cn, nil, new(methodHandler), ppath, pnames, g)
 				n.addHandler(method, h)
+				n.constraint = paramConstraintOf(path, search[j:], pcs)
 				cn.addChild(n)
+				return n
 			}
 		} else if j < sl {
 			search = search[j:]
 			c := cn.findChildWithLabel(search[0])
+			if search[0] == ':' {
+				c = cn.findParamChild(paramConstraintOf(path, search, pcs))
+			}
 			if c != nil {
 				// Go deeper
 				cn = c
 				continue
 			}
 			// Create child node
-			n := newNode(t, search, cn, nil, new(methodHandler), ppath, pnames, l)
+			n := newNode(t, search, cn, nil, new(methodHandler), ppath, pnames, g)
 			n.addHandler(method, h)
+			n.constraint = paramConstraintOf(path, search, pcs)
 			cn.addChild(n)
+			return n
 		} else {
 			// Node already exists
 			if h != nil {
 				cn.addHandler(method, h)
-				cn.ppath = path
+				cn.ppath = ppath
 				cn.pnames = pnames
-				cn.lars = l
+				cn.group = g
 			}
 		}
-		return
+		return cn
 	}
 }
 
-func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string, l *LARS) *node {
+func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string, g *RouteGroup) *node {
 	return &node{
 		kind:          t,
 		label:         pre[0],
@@ -187,14 +789,72 @@ func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath s
 		ppath:         ppath,
 		pnames:        pnames,
 		methodHandler: mh,
-		lars:          l,
+		group:         g,
+	}
+}
+
+// paramConstraintOf returns the constraint of the parameter at the start of
+// search, the remainder of path, or nil when it doesn't start with one.
+func paramConstraintOf(path, search string, pcs []*paramConstraint) *paramConstraint {
+	if search[0] != ':' {
+		return nil
 	}
+	return pcs[strings.Count(path[:len(path)-len(search)], ":")]
 }
 
+// addChild adds the child, params with constraints being placed before an
+// unconstrained param at the same position so that they're tried first.
 func (n *node) addChild(c *node) {
+	if c.kind == pkind && c.constraint != nil {
+		for i, s := range n.children {
+			if s.kind == pkind && s.constraint == nil {
+				n.children = append(n.children[:i], append(children{c}, n.children[i:]...)...)
+				return
+			}
+		}
+	}
+
 	n.children = append(n.children, c)
 }
 
+// findParamChildFrom returns the first param child at or after index i.
+func (n *node) findParamChildFrom(i int) *node {
+	for ; i < len(n.children); i++ {
+		if n.children[i].kind == pkind {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// findParamMatch returns the first param child at or after index i whose
+// constraint is satisfied by the segment at the start of search, along with its
+// index.
+func (n *node) findParamMatch(search string, i int) (*node, int) {
+	j := strings.IndexByte(search, '/')
+	if j == -1 {
+		j = len(search)
+	}
+
+	for ; i < len(n.children); i++ {
+		if c := n.children[i]; c.kind == pkind && c.constraint.match(search[:j]) {
+			return c, i
+		}
+	}
+	return nil, i
+}
+
+// findParamChild returns the param child with the constraint, params at the
+// same position with different constraints being siblings.
+func (n *node) findParamChild(pc *paramConstraint) *node {
+	for _, c := range n.children {
+		if c.kind == pkind && c.constraint.String() == pc.String() {
+			return c
+		}
+	}
+	return nil
+}
+
 func (n *node) findChild(l byte, t kind) *node {
 	for _, c := range n.children {
 		if c.label == l && c.kind == t {
@@ -223,69 +883,194 @@ func (n *node) findChildByKind(t kind) *node {
 }
 
 func (n *node) addHandler(method string, h HandlerFunc) {
+	n.methodHandler.setHandler(method, h)
+}
+
+func (mh *methodHandler) setHandler(method string, h HandlerFunc) {
 	switch method {
 	case GET:
-		n.methodHandler.get = h
+		mh.get = h
 	case POST:
-		n.methodHandler.post = h
+		mh.post = h
 	case PUT:
-		n.methodHandler.put = h
+		mh.put = h
 	case DELETE:
-		n.methodHandler.delete = h
+		mh.delete = h
 	case PATCH:
-		n.methodHandler.patch = h
+		mh.patch = h
 	case OPTIONS:
-		n.methodHandler.options = h
+		mh.options = h
 	case HEAD:
-		n.methodHandler.head = h
+		mh.head = h
 	case CONNECT:
-		n.methodHandler.connect = h
+		mh.connect = h
 	case TRACE:
-		n.methodHandler.trace = h
+		mh.trace = h
+	case methodAny:
+		mh.any = h
+	default:
+		if h == nil {
+			delete(mh.custom, method)
+			break
+		}
+		if mh.custom == nil {
+			mh.custom = make(map[string]HandlerFunc)
+		}
+		mh.custom[method] = h
+	}
+
+	mh.computeAllow()
+}
+
+// computeAllow recalculates the Allow header values from the registered
+// methods, sorted alphabetically, for every combination of automatic methods.
+func (mh *methodHandler) computeAllow() {
+	for flags := range mh.allow {
+		var allow []string
+		var registered bool
+
+		for _, m := range methods {
+			switch {
+			case mh.handler(m) != nil:
+				registered = true
+			case m == OPTIONS && flags&autoOptions != 0:
+			case m == HEAD && flags&autoHead != 0 && mh.get != nil:
+			default:
+				continue
+			}
+			allow = append(allow, m)
+		}
+
+		for m, h := range mh.custom {
+			if h != nil {
+				allow = append(allow, m)
+				registered = true
+			}
+		}
+
+		if !registered {
+			allow = nil
+		}
+
+		sort.Strings(allow)
+
+		mh.allow[flags] = strings.Join(allow, ", ")
 	}
 }
 
 func (n *node) findHandler(method string) HandlerFunc {
+	return n.methodHandler.handler(method)
+}
+
+func (mh *methodHandler) handler(method string) HandlerFunc {
 	switch method {
 	case GET:
-		return n.methodHandler.get
+		return mh.get
 	case POST:
-		return n.methodHandler.post
+		return mh.post
 	case PUT:
-		return n.methodHandler.put
+		return mh.put
 	case DELETE:
-		return n.methodHandler.delete
+		return mh.delete
 	case PATCH:
-		return n.methodHandler.patch
+		return mh.patch
 	case OPTIONS:
-		return n.methodHandler.options
+		return mh.options
 	case HEAD:
-		return n.methodHandler.head
+		return mh.head
 	case CONNECT:
-		return n.methodHandler.connect
+		return mh.connect
 	case TRACE:
-		return n.methodHandler.trace
+		return mh.trace
 	default:
+		if h := mh.custom[method]; h != nil {
+			return h
+		}
+		return mh.any
+	}
+}
+
+// findMethodHandler returns the node's handler for method, falling back to
+// the GET handler for HEAD requests when AutomaticHEAD is enabled.
+func (r *router) findMethodHandler(n *node, method string, ctx *Context) HandlerFunc {
+	h := n.findHandler(method)
+
+	if h == nil && method == HEAD && r.lars.AutomaticHEAD {
+		if h = n.methodHandler.get; h != nil {
+			ctx.head = true
+		}
+	}
+
+	return h
+}
+
+// check405 returns the handler, wrapped by the chain's middleware, to use when
+// the node has handlers registered but none for the requested method; nil is
+// returned when it has none at all. The Allow header value is stored on the
+// Context for the handler to use.
+func (n *node) check405(method string, l *LARS, ch *chain, ctx *Context) HandlerFunc {
+	var flags int
+
+	if l.AutomaticOPTIONS {
+		flags |= autoOptions
+	}
+
+	if l.AutomaticHEAD {
+		flags |= autoHead
+	}
+
+	if ctx.allow = n.methodHandler.allow[flags]; ctx.allow == "" {
 		return nil
 	}
+
+	if method == OPTIONS && l.AutomaticOPTIONS {
+		return ch.handlers().automaticOptions
+	}
+
+	return ch.handlers().methodNotAllowed
 }
 
-func (n *node) check405(l *LARS) HandlerFunc {
-	for _, m := range methods {
-		if h := n.findHandler(m); h != nil {
-			return methodNotAllowedHandler
+func (r *router) find(method, path string, ctx *Context) (h HandlerFunc, g *RouteGroup) {
+	s := r.snapshot()
+
+	if h, g = r.lookup(s, method, path, ctx); h != nil {
+		return
+	}
+	return r.findNotFound(s, path)
+}
+
+// lookup returns the handler for method and path, including redirects to the
+// fixed path, or nil when no route matches. The snapshot is loaded once per
+// request so that every step sees the same tree while routes change.
+func (r *router) lookup(s *snapshot, method, path string, ctx *Context) (h HandlerFunc, g *RouteGroup) {
+	ctx.head = false
+
+	if h, g = r.findInner(s, method, path, ctx); h != nil {
+		return
+	}
+
+	if fixed, fh, fl := r.fixPath(s, method, path, ctx); fh != nil {
+		if r.lars.ServeFixedPath {
+			return fh, fl
 		}
+		return fl.chain.handlers().then(redirect(fixed, method, r.lars.UseEscapedPath)), fl
 	}
-	return nil
+
+	return nil, nil
 }
 
-func (r *router) find(method, path string, ctx *Context) (h HandlerFunc, l *LARS) {
-	return r.findInner(method, path, ctx, r.lars.FixTrailingSlash, false)
+// findNotFound returns the handler for requests not matching any route, that
+// of the group with the longest prefix matching path.
+func (r *router) findNotFound(s *snapshot, path string) (HandlerFunc, *RouteGroup) {
+	g := matchGroup(s.notFound, path, &r.lars.RouteGroup)
+	return g.chain.handlers().notFound, g
 }
 
-func (r *router) findInner(method, path string, ctx *Context, fixTrailingSlash bool, callingFromNotFound bool) (h HandlerFunc, l *LARS) {
-	l = r.lars
-	cn := r.tree // Current node as root
+// findInner returns the handler for method and path, or nil when no route
+// matches.
+func (r *router) findInner(s *snapshot, method, path string, ctx *Context) (h HandlerFunc, g *RouteGroup) {
+	g = &r.lars.RouteGroup
+	cn := s.tree // Current node as root
 
 	var (
 		search = path
@@ -294,6 +1079,9 @@ func (r *router) findInner(method, path string, ctx *Context, fixTrailingSlash b
 		nk     kind   // Next kind
 		nn     *node  // Next node
 		ns     string // Next search
+		np     int    // Next param counter
+		pi     int    // Param child index, the first param child to try
+		npi    int    // Next param child index
 	)
 
 	// Search order static > param > match-any
@@ -322,17 +1110,16 @@ func (r *router) findInner(method, path string, ctx *Context, fixTrailingSlash b
 			// Continue search
 			search = search[i:]
 		} else {
-			cn = nn
-			search = ns
-			if nk == pkind {
-				goto Param
-			} else if nk == mkind {
-				goto MatchAny
-			} else {
+			if nn == nil {
 				// Not found
 				goto NotFound
-				// return
 			}
+			cn, search, n, pi = nn, ns, np, npi
+			nn = nil
+			if nk == pkind {
+				goto Param
+			}
+			goto MatchAny
 		}
 
 		if search == "" {
@@ -343,125 +1130,122 @@ func (r *router) findInner(method, path string, ctx *Context, fixTrailingSlash b
 		c = cn.findChild(search[0], skind)
 		if c != nil {
 			// Save next
-			if cn.label == '/' {
+			if cn.findChildByKind(pkind) != nil || cn.findChildByKind(mkind) != nil {
 				nk = pkind
 				nn = cn
 				ns = search
+				np = n
+				npi = 0
 			}
 			cn = c
 			continue
 		}
 
-		// Param node
+		// Param node, params with constraints being tried in order before
+		// an unconstrained param; a value satisfying none of them falls
+		// through to match-any
 	Param:
-		c = cn.findChildByKind(pkind)
-		if c != nil {
+		if c, pi = cn.findParamMatch(search, pi); c != nil {
+			i, j := 0, len(search)
+			for ; i < j && search[i] != '/'; i++ {
+			}
+
 			// Save next
-			if cn.label == '/' {
+			if cn.findParamChildFrom(pi+1) != nil {
+				nk = pkind
+				nn = cn
+				ns = search
+				np = n
+				npi = pi + 1
+			} else if cn.findChildByKind(mkind) != nil {
 				nk = mkind
 				nn = cn
 				ns = search
+				np = n
 			}
+			pi = 0
 			cn = c
-			i, j := 0, len(search)
-			for ; i < j && search[i] != '/'; i++ {
-			}
-			ctx.pvalues[n] = search[:i]
+			ctx.setParam(n, search[:i])
 			n++
 			search = search[i:]
 			continue
 		}
+		pi = 0
 
 		// Match-any node
 	MatchAny:
-		if cn = cn.findChildByKind(mkind); cn == nil {
+		if c = cn.findChildByKind(mkind); c == nil {
+			// Backtrack to the last saved node, if not already tried, as
+			// constraints may have caused a dead end deeper in the tree
+			if nn != nil && nn != cn {
+				cn, search, n, pi = nn, ns, np, npi
+				nn = nil
+				if nk == pkind {
+					goto Param
+				}
+				goto MatchAny
+			}
 			// Not found
 			goto NotFound
 			// return
 		}
-		ctx.pvalues[len(cn.pnames)-1] = search
+		cn = c
+		ctx.setParam(len(cn.pnames)-1, search)
 		goto End
 	}
 
 End:
 	ctx.path = cn.ppath
 	ctx.pnames = cn.pnames
-	h = cn.findHandler(method)
+	h = r.findMethodHandler(cn, method, ctx)
 
-	if cn.lars != nil {
-		l = cn.lars
+	if cn.group != nil {
+		g = cn.group
 	}
 
 	// NOTE: Slow zone...
 	if h == nil {
 
-		h = cn.check405(l.lars)
-
-		if h == nil {
-			goto NotFound
-		}
-
 		// Dig further for match-any, might have an empty value for *, e.g.
-		if cn = cn.findChildByKind(mkind); cn == nil {
-			// Not Found
-			// if h == nil {
-			// 	goto NotFound
-			// }
-			return
+		if c = cn.findChildByKind(mkind); c != nil {
+			if h = r.findMethodHandler(c, method, ctx); h != nil {
+				ctx.path = c.ppath
+				ctx.pnames = c.pnames
+				ctx.setParam(len(c.pnames)-1, "")
+
+				if c.group != nil {
+					g = c.group
+				}
+				return
+			}
 		}
 
-		ctx.pvalues[len(cn.pnames)-1] = ""
+		// a group with a custom handler takes precedence over the node's
+		groups := s.methodNotAllowed
+		mg := matchGroup(groups, path, g)
 
-		if h = cn.findHandler(method); h == nil {
-			h = cn.check405(l.lars)
+		if h = cn.check405(method, r.lars, mg.chain, ctx); h == nil && c != nil && c.group != nil {
+			mg = matchGroup(groups, path, c.group)
+			h = c.check405(method, r.lars, mg.chain, ctx)
 		}
 
 		if h == nil {
 			goto NotFound
 		}
-	}
-
-	return
-
-NotFound:
-
-	// Trailing Slash Checks + Case Insensitive
-	//
-	if !fixTrailingSlash {
-		if !callingFromNotFound {
-			h = l.http404
-		}
-		return
-	}
-
-	path = strings.ToLower(path)
 
-	// Check Same path, but all lowercase
-	if h, l = r.findInner(method, path, ctx, false, true); h != nil {
-		h = redirect(path, method)
-		return
-	}
-
-	// determine if has a slash at end and add or remove it
-	if path[len(path)-1:] == basePath {
-		path = path[:len(path)-1]
-	} else {
-		path = path + basePath
-	}
-
-	// Check lowercased path with opposite adding or removing slash
-	if h, l = r.findInner(method, path, ctx, false, true); h != nil {
-		h = redirect(path, method)
-		return
+		g = mg
 	}
 
-	h = l.http404
-
 	return
 
+NotFound:
+	return nil, g
 }
 
-func redirect(url, method string) (h HandlerFunc) {
+// redirect returns a handler redirecting to the fixed path, keeping the
+// request's query string and encoding. The fixed path is already escaped when
+// routing on the escaped path.
+func redirect(fixed, method string, escaped bool) (h HandlerFunc) {
 
 	code := http.StatusMovedPermanently
 	if method != GET {
@@ -469,6 +1253,14 @@ func redirect(url, method string) (h HandlerFunc) {
 	}
 
 	return func(c *Context) {
-		http.Redirect(c.Response, c.Request, url, code)
+		loc := fixed
+
+		if !escaped {
+			loc = location(c.Request.URL, fixed)
+		} else if q := c.Request.URL.RawQuery; q != "" {
+			loc += "?" + q
+		}
+
+		http.Redirect(c.Response, c.Request, loc, code)
 	}
 }
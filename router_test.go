@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
 	. "gopkg.in/go-playground/assert.v1"
@@ -20,6 +21,12 @@ import (
 // go test -coverprofile cover.out && go tool cover -html=cover.out -o cover.html
 //
 
+type route struct {
+	Method  string
+	Path    string
+	Handler Handler
+}
+
 var (
 	api = []route{
 		// OAuth Authorizations
@@ -524,6 +531,7 @@ func TestRedirectFunctionality(t *testing.T) {
 
 	l := New()
 	l.FixTrailingSlash = false
+	l.FixCase = false
 
 	r := l.router
 
@@ -550,6 +558,7 @@ func TestRedirectFunctionality(t *testing.T) {
 	Equal(t, c, http.StatusNotFound)
 
 	l.FixTrailingSlash = true
+	l.FixCase = true
 
 	c, _ = request(GET, "/users", l)
 	Equal(t, c, http.StatusOK)
@@ -570,6 +579,320 @@ func TestRedirectFunctionality(t *testing.T) {
 	Equal(t, c, http.StatusTemporaryRedirect)
 }
 
+func TestPathNormalization(t *testing.T) {
+	l := New()
+	l.CleanPath = true
+
+	l.Get("/users/:id/files", func(c *Context) {
+		c.Response.Write([]byte(c.Param("id")))
+	})
+	l.Get("/docs/", func(*Context) {})
+	l.Post("/forms/", func(*Context) {})
+	l.Get("/a b/c", func(*Context) {})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{GET, "/users/John/files/?x=1&y=%20", http.StatusMovedPermanently, "/users/John/files?x=1&y=%20"},
+		{GET, "/USERS/John/Files", http.StatusMovedPermanently, "/users/John/files"},
+		{GET, "/Users/John/files/", http.StatusMovedPermanently, "/users/John/files"},
+		{GET, "/users//John/./files", http.StatusMovedPermanently, "/users/John/files"},
+		{GET, "/docs/../users/1/files?q", http.StatusMovedPermanently, "/users/1/files?q"},
+		{GET, "/docs", http.StatusMovedPermanently, "/docs/"},
+		{GET, "/users/a%2Fb/files/", http.StatusNotFound, ""},
+		{GET, "/users/a%3Fb/files/?q=1", http.StatusMovedPermanently, "/users/a%3Fb/files?q=1"},
+		{GET, "/A%20B/c", http.StatusMovedPermanently, "/a%20b/c"},
+		{POST, "/Docs/", http.StatusNotFound, ""},
+		{POST, "/Forms/", http.StatusTemporaryRedirect, "/forms/"},
+		{GET, "/Forms/", http.StatusNotFound, ""},
+		{GET, "/missing", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+		Equal(t, w.Code, tt.code)
+		Equal(t, w.Header().Get("Location"), tt.location)
+	}
+
+	// options are independent of each other
+	l.FixCase = false
+
+	code, _ := request(GET, "/Users/John/files", l)
+	Equal(t, code, http.StatusNotFound)
+
+	code, _ = request(GET, "/users/John/files/", l)
+	Equal(t, code, http.StatusMovedPermanently)
+
+	l.FixTrailingSlash = false
+
+	code, _ = request(GET, "/users/John/files/", l)
+	Equal(t, code, http.StatusNotFound)
+
+	code, _ = request(GET, "/users//John/files", l)
+	Equal(t, code, http.StatusMovedPermanently)
+
+	// serving directly instead of redirecting
+	l.FixCase = true
+	l.ServeFixedPath = true
+
+	code, body := request(GET, "/USERS/John/files", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "John")
+}
+
+func TestRouterEscapedPath(t *testing.T) {
+	l := New()
+	l.Get("/files/:name", func(c *Context) {
+		c.Response.Write([]byte(c.Param("name") + "|" + c.RawParam("name") + "|" + c.P(0)))
+	})
+	l.Get("/static/*", func(c *Context) {
+		c.Response.Write([]byte(c.Param("_*")))
+	})
+
+	// the encoded slash splits the value into separate segments
+	code, _ := request(GET, "/files/a%2Fb", l)
+	Equal(t, code, http.StatusNotFound)
+
+	code, body := request(GET, "/files/a%20b", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "a b|a b|a b")
+
+	l.UseEscapedPath = true
+
+	code, body = request(GET, "/files/a%2Fb", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "a/b|a%2Fb|a/b")
+
+	code, body = request(GET, "/static/css/a%2Fb.css", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "css/a/b.css")
+
+	r, _ := http.NewRequest(GET, "/Files/a%2Fb/?q=1", nil)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMovedPermanently)
+	Equal(t, w.Header().Get("Location"), "/files/a%2Fb?q=1")
+}
+
+func TestRouterLateRegistration(t *testing.T) {
+	l := New()
+	l.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte(c.Param("id")))
+	})
+
+	// pools a Context sized for a single parameter
+	code, body := request(GET, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "1")
+
+	l.Get("/users/:id/files/:fid/versions/:vid", func(c *Context) {
+		c.Response.Write([]byte(c.Param("id") + c.Param("fid") + c.Param("vid")))
+	})
+
+	code, body = request(GET, "/users/1/files/2/versions/3", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "123")
+
+	code, body = request(GET, "/users/4", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "4")
+}
+
+func TestRouterRemove(t *testing.T) {
+	l := New()
+	h := func(c *Context) {
+		c.Response.Write([]byte(c.Path()))
+	}
+
+	l.Get("/users/:id", h).Name("user")
+	l.Post("/users/:id", h)
+	l.Get("/users/:id/files/*", h)
+	l.Handle("PURGE", "/cache", h)
+
+	g := l.Group("/admin")
+	g.Get("/stats", h)
+
+	code, _ := request(GET, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+
+	Equal(t, l.Remove(GET, "/users/:uid"), true)
+	Equal(t, l.Remove(GET, "/users/:id"), false)
+	Equal(t, l.Remove(PUT, "/users/:id"), false)
+
+	code, _ = request(GET, "/users/1", l)
+	Equal(t, code, http.StatusMethodNotAllowed)
+
+	code, _ = request(POST, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+
+	code, body := request(GET, "/users/1/files/a", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "/users/:id/files/*")
+
+	_, err := l.URIFor("user", 1)
+	NotEqual(t, err, nil)
+
+	Equal(t, l.Remove(POST, "/users/:id"), true)
+	Equal(t, l.Remove(GET, "/users/:id/files/*"), true)
+	Equal(t, l.Remove("PURGE", "/cache"), true)
+	Equal(t, g.Remove(GET, "/stats"), true)
+
+	for _, path := range []string{"/users/1", "/users/1/files/a", "/cache", "/admin/stats"} {
+		code, _ = request(GET, path, l)
+		Equal(t, code, http.StatusNotFound)
+	}
+
+	Equal(t, len(l.Routes()), 0)
+
+	// the routes may be registered again, with different parameters
+	l.Get("/users/:id<int>", h)
+
+	code, body = request(GET, "/users/2", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "/users/:id<int>")
+
+	code, _ = request(GET, "/users/abc", l)
+	Equal(t, code, http.StatusNotFound)
+}
+
+func TestRouterConcurrentUpdates(t *testing.T) {
+	l := New()
+	l.Get("/static", func(c *Context) {
+		c.Response.Write([]byte("static"))
+	})
+	l.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte(c.Param("id")))
+	})
+
+	// start serving so that updates are copy-on-write
+	request(GET, "/static", l)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				code, body := request(GET, "/static", l)
+				Equal(t, code, http.StatusOK)
+				Equal(t, body, "static")
+
+				code, body = request(GET, "/users/7", l)
+				Equal(t, code, http.StatusOK)
+				Equal(t, body, "7")
+
+				if code, body = request(GET, "/dynamic/1/2/3", l); code == http.StatusOK {
+					Equal(t, body, "123")
+				} else {
+					Equal(t, code, http.StatusNotFound)
+				}
+
+				code, _ = request(GET, "/missing", l)
+				Equal(t, code, http.StatusNotFound)
+
+				code, _ = request(GET, "/group0/missing", l)
+				Equal(t, code, http.StatusNotFound)
+
+				code, _ = request(POST, "/static", l)
+				Equal(t, code, http.StatusMethodNotAllowed)
+			}
+		}()
+	}
+
+	next := func(h HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			h(c)
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		l.Get("/dynamic/:a/:b/:c", func(c *Context) {
+			c.Response.Write([]byte(c.Param("a") + c.Param("b") + c.Param("c")))
+		})
+		l.Host(fmt.Sprintf("host%d.example.com", i)).Get("/", func(*Context) {})
+		g := l.Group(fmt.Sprintf("/group%d", i))
+		g.Get("/", func(*Context) {})
+		g.NotFound(func(c *Context) {
+			c.Response.WriteHeader(http.StatusNotFound)
+		})
+		g.MethodNotAllowed(func(c *Context) {
+			c.Response.WriteHeader(http.StatusMethodNotAllowed)
+		})
+		if i%50 == 0 {
+			l.Use(MiddlewareFunc(next))
+			l.UseBefore(MiddlewareFunc(next))
+		}
+		Equal(t, l.Remove(GET, "/dynamic/:a/:b/:c"), true)
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestRouterOptionalParams(t *testing.T) {
+	l := New()
+	l.Get("/archive/:year<int>/:month?/:day?", func(c *Context) {
+		c.Response.Write([]byte(c.Path() + "|" + c.Param("year") + "|" + c.Param("month") + "|" + c.Param("day")))
+	})
+	l.Get("/static/*filepath", func(c *Context) {
+		c.Response.Write([]byte(c.Param("filepath")))
+	})
+	l.Get("/:lang?", func(c *Context) {
+		c.Response.Write([]byte("home " + c.Param("lang")))
+	})
+
+	tests := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{"/archive/2020", http.StatusOK, "/archive/:year<int>/:month?/:day?|2020||"},
+		{"/archive/2020/05", http.StatusOK, "/archive/:year<int>/:month?/:day?|2020|05|"},
+		{"/archive/2020/05/01", http.StatusOK, "/archive/:year<int>/:month?/:day?|2020|05|01"},
+		{"/archive/abc", http.StatusNotFound, "404 page not found\n"},
+		{"/archive/2020/05/01/02", http.StatusNotFound, "404 page not found\n"},
+		{"/static/css/main.css", http.StatusOK, "css/main.css"},
+		{"/static/", http.StatusOK, ""},
+		{"/", http.StatusOK, "home "},
+		{"/en", http.StatusOK, "home en"},
+	}
+
+	for _, tt := range tests {
+		code, body := request(GET, tt.path, l)
+		Equal(t, code, tt.code)
+		Equal(t, body, tt.expected)
+	}
+
+	routes := l.Routes()
+	Equal(t, routes[0].Params, []string{"year", "month", "day"})
+	Equal(t, routes[1].Params, []string{"filepath"})
+
+	// overlapping with a path matched by the optional parameter
+	PanicMatches(t, func() { l.Get("/archive/:year<int>", conflictHandlerA) }, "lars => route 'GET /archive/:year<int>' (github.com/go-playground/lars.conflictHandlerA) conflicts with existing route 'GET /archive/:year<int>/:month?/:day?' (github.com/go-playground/lars.TestRouterOptionalParams.func1)")
+	PanicMatches(t, func() { l.Get("/a/:b?/c", func(*Context) {}) }, "lars => optional parameters must be the last segments of path '/a/:b?/c'")
+	PanicMatches(t, func() { l.Get("/a/:b?c", func(*Context) {}) }, "lars => optional parameter must be a whole segment of path '/a/:b?c'")
+	PanicMatches(t, func() { l.Get("/a/*b/c", func(*Context) {}) }, "lars => match-any must be the last segment of path '/a/*b/c'")
+
+	// removing the route removes every path it matches
+	Equal(t, l.Remove(GET, "/archive/:year<int>/:month?/:day?"), true)
+
+	code, _ := request(GET, "/archive/2020", l)
+	Equal(t, code, http.StatusNotFound)
+}
+
 func TestRouterServeHTTP(t *testing.T) {
 	r := New()
 	r.Get("/users", func(*Context) {})
@@ -587,6 +910,394 @@ func TestRouterServeHTTP(t *testing.T) {
 	Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestRouterExtensionMethods(t *testing.T) {
+	l := New()
+
+	l.Handle("PROPFIND", "/dav", func(c *Context) {
+		c.Response.Write([]byte("propfind"))
+	})
+	l.Handle("MKCOL", "/dav", func(c *Context) {
+		c.Response.Write([]byte("mkcol"))
+	})
+	l.Handle(GET, "/dav", func(c *Context) {
+		c.Response.Write([]byte("get"))
+	})
+	l.Any("/any", func(c *Context) {
+		c.Response.Write([]byte(c.Request.Method))
+	})
+	l.Handle("PURGE", "/any", func(c *Context) {
+		c.Response.Write([]byte("purged"))
+	})
+
+	code, body := request("PROPFIND", "/dav", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "propfind")
+
+	code, body = request("MKCOL", "/dav", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "mkcol")
+
+	code, body = request(GET, "/dav", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "get")
+
+	r, _ := http.NewRequest("LOCK", "/dav", nil)
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	Equal(t, w.Code, http.StatusMethodNotAllowed)
+	Equal(t, w.Header().Get(Allow), "GET, MKCOL, PROPFIND")
+
+	code, body = request("LOCK", "/any", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "LOCK")
+
+	code, body = request(DELETE, "/any", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, DELETE)
+
+	code, body = request("PURGE", "/any", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "purged")
+
+	PanicMatches(t, func() { l.Handle("", "/bad", func(*Context) {}) }, "lars => invalid method ''")
+	PanicMatches(t, func() { l.Handle("BAD METHOD", "/bad", func(*Context) {}) }, "lars => invalid method 'BAD METHOD'")
+	PanicMatches(t, func() { l.Handle("*", "/bad", func(*Context) {}) }, "lars => invalid method '*'")
+
+	// the handler serving extension methods on Any routes is reported as *
+	var anyMethods []string
+	for _, r := range l.Routes() {
+		if r.Path == "/any" {
+			anyMethods = append(anyMethods, r.Method)
+		}
+	}
+	Equal(t, anyMethods, []string{CONNECT, DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT, TRACE, "*", "PURGE"})
+
+	// and removed by the same name, leaving the other methods in place
+	Equal(t, l.Remove("*", "/any"), true)
+	Equal(t, l.Remove("*", "/any"), false)
+
+	code, _ = request("LINK", "/any", l)
+	Equal(t, code, http.StatusMethodNotAllowed)
+
+	code, body = request("PURGE", "/any", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "purged")
+}
+
+func TestRouterParamConstraints(t *testing.T) {
+	l := New()
+
+	l.Get("/users/:id<int>", func(c *Context) {
+		c.Response.Write([]byte("int:" + c.Param("id")))
+	})
+	l.Get("/users/:id<int>/files/:name<[a-z0-9-]+>", func(c *Context) {
+		c.Response.Write([]byte(c.Param("id") + ":" + c.Param("name")))
+	})
+	l.Get("/users/*", func(c *Context) {
+		c.Response.Write([]byte("any:" + c.Param("_*")))
+	})
+	l.Get("/posts/:slug<uuid>", func(c *Context) {
+		c.Response.Write([]byte(c.Param("slug")))
+	})
+	l.Get("/tags/:tag<alpha>", func(*Context) {})
+	l.Get("/codes/:code<alnum>", func(*Context) {})
+	l.Get("/pages/:page<uint>", func(*Context) {})
+	l.Get("/nested/:v<a<b>c>", func(*Context) {})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/1", http.StatusOK, "int:1"},
+		{"/users/-10", http.StatusOK, "int:-10"},
+		{"/users/joe", http.StatusOK, "any:joe"},
+		{"/users/1/files/read-me", http.StatusOK, "1:read-me"},
+		{"/users/1/files/READ_ME", http.StatusOK, "any:1/files/READ_ME"},
+		{"/users/joe/files/read-me", http.StatusOK, "any:joe/files/read-me"},
+		{"/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", http.StatusOK, "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"/posts/6ba7b810-9dad-11d1-80b4-00c04fd430cz", http.StatusNotFound, "404 page not found\n"},
+		{"/posts/hello-world", http.StatusNotFound, "404 page not found\n"},
+		{"/posts/%10%11%12%13%14%15%16%17-1234-1234-1234-123456789012", http.StatusNotFound, "404 page not found\n"},
+		{"/tags/Golang", http.StatusOK, ""},
+		{"/tags/go1", http.StatusNotFound, "404 page not found\n"},
+		{"/codes/go1", http.StatusOK, ""},
+		{"/codes/go-1", http.StatusNotFound, "404 page not found\n"},
+		{"/pages/1", http.StatusOK, ""},
+		{"/pages/-1", http.StatusNotFound, "404 page not found\n"},
+		{"/nested/a<b>c", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		code, body := request(GET, tt.path, l)
+		Equal(t, code, tt.code)
+		Equal(t, body, tt.body)
+	}
+
+	// constraints are excluded from param names but kept in the route path
+	c := l.pool.New().(*Context)
+	l.router.find(GET, "/users/1/files/a", c)
+	Equal(t, c.Params(), []string{"id", "name"})
+	Equal(t, c.Path(), "/users/:id<int>/files/:name<[a-z0-9-]+>")
+
+	PanicMatches(t, func() { l.Get("/bad/:id<[a-z>", func(*Context) {}) }, "lars => invalid parameter constraint '[a-z': error parsing regexp: missing closing ]: `[a-z)$`")
+	PanicMatches(t, func() { l.Get("/bad/:id<int", func(*Context) {}) }, "lars => unterminated parameter constraint in '/bad/:id<int'")
+	PanicMatches(t, func() { l.Get("/bad/:id<>", func(*Context) {}) }, "lars => empty parameter constraint")
+
+	// a route with an invalid constraint leaves the tree unmodified
+	PanicMatches(t, func() { l.Get("/users/:id<int>/files/:name<[a-z>", func(*Context) {}) }, "lars => invalid parameter constraint '[a-z': error parsing regexp: missing closing ]: `[a-z)$`")
+	PanicMatches(t, func() { l.Get("/pages/:page<uint>/:n<>", func(*Context) {}) }, "lars => empty parameter constraint")
+
+	code, body := request(GET, "/users/1/files/read-me", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "1:read-me")
+
+	code, _ = request(GET, "/pages/1/2", l)
+	Equal(t, code, http.StatusNotFound)
+}
+
+func TestRouterParamConstraintSiblings(t *testing.T) {
+	l := New()
+
+	l.Get("/posts/:id<int>", func(c *Context) {
+		c.Response.Write([]byte("id:" + c.Param("id")))
+	})
+	l.Get("/posts/:slug<uuid>", func(c *Context) {
+		c.Response.Write([]byte("slug:" + c.Param("slug")))
+	})
+	l.Get("/users/:id/books", func(c *Context) {
+		c.Response.Write([]byte("books:" + c.Param("id")))
+	})
+	l.Get("/users/:id<uint>/books", func(c *Context) {
+		c.Response.Write([]byte("uint:" + c.Param("id")))
+	})
+	l.Get("/users/:id<uint>/files", func(c *Context) {
+		c.Response.Write([]byte("files:" + c.Param("id")))
+	})
+	l.Get("/users/:name/profile", func(c *Context) {
+		c.Response.Write([]byte("profile:" + c.Param("name")))
+	})
+	l.Get("/items/:id<int>/info", func(c *Context) {
+		c.Response.Write([]byte("info:" + c.Param("id")))
+	})
+	l.Get("/items/:id<uuid>/info", func(c *Context) {
+		c.Response.Write([]byte("info:" + c.Param("id")))
+	})
+	l.Get("/items/*", func(c *Context) {
+		c.Response.Write([]byte("any:" + c.Param("_*")))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/posts/1", http.StatusOK, "id:1"},
+		{"/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", http.StatusOK, "slug:6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"/posts/hello", http.StatusNotFound, "404 page not found\n"},
+		{"/users/1/books", http.StatusOK, "uint:1"},
+		{"/users/joe/books", http.StatusOK, "books:joe"},
+		{"/users/1/files", http.StatusOK, "files:1"},
+		{"/users/1/profile", http.StatusOK, "profile:1"},
+		{"/users/joe/files", http.StatusNotFound, "404 page not found\n"},
+		{"/items/1/info", http.StatusOK, "info:1"},
+		{"/items/6ba7b810-9dad-11d1-80b4-00c04fd430c8/info", http.StatusOK, "info:6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"/items/x/info", http.StatusOK, "any:x/info"},
+		{"/items/1/other", http.StatusOK, "any:1/other"},
+	}
+
+	for _, tt := range tests {
+		code, body := request(GET, tt.path, l)
+		Equal(t, code, tt.code)
+		Equal(t, body, tt.body)
+	}
+
+	// params at the same position keep their own names
+	c := l.pool.New().(*Context)
+	l.router.find(GET, "/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8", c)
+	Equal(t, c.Params(), []string{"slug"})
+	Equal(t, c.Path(), "/posts/:slug<uuid>")
+}
+
+func TestRouterBacktracking(t *testing.T) {
+	l := New()
+
+	l.Get("/users/new", func(c *Context) {
+		c.Response.Write([]byte("new"))
+	})
+	l.Get("/users/:id/files", func(c *Context) {
+		c.Response.Write([]byte("files:" + c.Param("id")))
+	})
+	l.Get("/users/:id/:name<int>/more", func(c *Context) {
+		c.Response.Write([]byte("more:" + c.Param("id") + c.Param("name")))
+	})
+	l.Get("/users/*", func(c *Context) {
+		c.Response.Write([]byte("any:" + c.Param("_*")))
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/users/new", "new"},
+		{"/users/new/files", "files:new"},
+		{"/users/1/files", "files:1"},
+		{"/users/1/2/more", "more:12"},
+		{"/users/1/a/more", "any:1/a/more"},
+		{"/users/new/other", "any:new/other"},
+	}
+
+	for _, tt := range tests {
+		code, body := request(GET, tt.path, l)
+		Equal(t, code, http.StatusOK)
+		Equal(t, body, tt.body)
+	}
+}
+
+func conflictHandlerA(*Context) {}
+func conflictHandlerB(*Context) {}
+
+func conflictParamHandler(c *Context) {
+	c.Response.Write([]byte("b" + c.Param("id")))
+}
+
+func TestRouterConflicts(t *testing.T) {
+	l := New()
+	l.Get("/users/:id", conflictHandlerA)
+	l.Get("/files/*", conflictHandlerA)
+	l.Any("/any", conflictHandlerA)
+	l.Post("/users/:id", conflictHandlerB)
+	l.Get("/users/:id/files", conflictHandlerB)
+
+	PanicMatches(t, func() { l.Get("/users/:id", conflictHandlerB) }, "lars => route 'GET /users/:id' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'GET /users/:id' (github.com/go-playground/lars.conflictHandlerA)")
+	PanicMatches(t, func() { l.Get("/users/:name", conflictHandlerB) }, "lars => route 'GET /users/:name' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'GET /users/:id' (github.com/go-playground/lars.conflictHandlerA)")
+	PanicMatches(t, func() { l.Put("/users/:name", conflictHandlerB) }, "lars => route 'PUT /users/:name' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'GET /users/:id' (github.com/go-playground/lars.conflictHandlerA)")
+	PanicMatches(t, func() { l.Get("/files/*", conflictHandlerB) }, "lars => route 'GET /files/*' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'GET /files/*' (github.com/go-playground/lars.conflictHandlerA)")
+	PanicMatches(t, func() { l.Any("/any", conflictHandlerB) }, "lars => route 'CONNECT /any' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'CONNECT /any' (github.com/go-playground/lars.conflictHandlerA)")
+
+	g := l.Group("/users")
+	PanicMatches(t, func() { g.Get("/:uid/files", conflictHandlerA) }, "lars => route 'GET /users/:uid/files' (github.com/go-playground/lars.conflictHandlerA) conflicts with existing route 'GET /users/:id/files' (github.com/go-playground/lars.conflictHandlerB)")
+
+	// override
+	l = New()
+	l.AllowRouteOverride = true
+	l.Get("/users/:id", func(c *Context) {
+		c.Response.Write([]byte("a"))
+	})
+	l.Get("/users/:id", conflictParamHandler)
+	l.Put("/users/:id", func(c *Context) {
+		c.Response.Write([]byte("c" + c.Param("id")))
+	})
+
+	// parameter names are shared by the node's methods so can't be overridden
+	PanicMatches(t, func() { l.Put("/users/:name", conflictHandlerB) }, "lars => route 'PUT /users/:name' (github.com/go-playground/lars.conflictHandlerB) conflicts with existing route 'GET /users/:id' (github.com/go-playground/lars.conflictParamHandler)")
+
+	code, body := request(GET, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "b1")
+
+	code, body = request(PUT, "/users/1", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "c1")
+
+	Equal(t, len(l.router.routes), 2)
+
+	// renaming is permitted when no other method uses the names
+	l.Get("/files/:id", conflictHandlerA)
+	l.Get("/files/:name", func(c *Context) {
+		c.Response.Write([]byte(c.Param("name")))
+	})
+
+	code, body = request(GET, "/files/readme", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "readme")
+
+	Equal(t, len(l.router.routes), 3)
+	Equal(t, l.Routes()[2].Path, "/files/:name")
+}
+
+// benchWriter is a no-op http.ResponseWriter, so benchmarks only measure the
+// router's own allocations.
+type benchWriter struct {
+	h http.Header
+}
+
+func (w *benchWriter) Header() http.Header {
+	return w.h
+}
+
+func (w *benchWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *benchWriter) WriteString(s string) (int, error) {
+	return len(s), nil
+}
+
+func (w *benchWriter) WriteHeader(int) {}
+
+func benchmarkRoute(b *testing.B, l *LARS, method, path string) {
+	req, _ := http.NewRequest(method, path, nil)
+	w := &benchWriter{h: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l.ServeHTTP(w, req)
+	}
+}
+
+func benchmarkLARS() *LARS {
+	l := New()
+	l.Use(func(c *Context) {
+		c.Response.Header()
+	})
+
+	g := l.Group("/api", func(c *Context) {
+		c.Response.Header()
+	})
+
+	g.Get("/users", func(c *Context) {
+		c.Response.WriteString("users")
+	})
+	g.Get("/users/:id/files/:fid", func(c *Context) {
+		c.Response.WriteString(c.Param("id"))
+		c.Response.WriteString(c.Param("fid"))
+	})
+
+	return l
+}
+
+// raceEnabled is set when testing with the race detector, which allocates.
+var raceEnabled bool
+
+func TestRouterAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are counted without the race detector")
+	}
+
+	l := benchmarkLARS()
+	w := &benchWriter{h: make(http.Header)}
+
+	for _, path := range []string{"/api/users", "/api/users/1/files/2"} {
+		req, _ := http.NewRequest(GET, path, nil)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			l.ServeHTTP(w, req)
+		})
+		Equal(t, allocs, float64(0))
+	}
+}
+
+func BenchmarkRouterStatic(b *testing.B) {
+	benchmarkRoute(b, benchmarkLARS(), GET, "/api/users")
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	benchmarkRoute(b, benchmarkLARS(), GET, "/api/users/1/files/2")
+}
+
 // func (n *node) printTree(pfx string, tail bool) {
 // 	p := prefix(tail, pfx, "└── ", "├── ")
 // 	fmt.Printf("%s%s, %p: type=%d, parent=%p, handler=%v\n", p, n.prefix, n, n.kind, n.parent, n.methodHandler)
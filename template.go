@@ -0,0 +1,203 @@
+package lars
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultTemplateExtension = ".html"
+	defaultLayoutDir         = "layouts"
+	defaultPartialDir        = "partials"
+)
+
+// TemplateRenderer is an html/template based Renderer which loads all
+// templates beneath a directory.
+//
+// Templates are named by their path relative to the directory, without the
+// extension, using forward slashes; eg. "users/show". Every template within
+// the layout and partial directories is shared by all pages, so a page may
+// reference "partials/nav" or invoke "layouts/main" and define the blocks it
+// uses. When a DefaultLayout is set the layout is executed instead of the
+// page itself, which is expected to only define blocks.
+type TemplateRenderer struct {
+	// Dir is the root directory of the templates.
+	Dir string
+
+	// Extension of the template files, defaults to ".html".
+	Extension string
+
+	// LayoutDir and PartialDir are the directories, relative to Dir, whose
+	// templates are shared by every page; default "layouts" and "partials".
+	LayoutDir  string
+	PartialDir string
+
+	// DefaultLayout is the name of the layout executed for every page, if any.
+	DefaultLayout string
+
+	// Funcs are added to every template prior to parsing.
+	Funcs template.FuncMap
+
+	// Development reloads the templates from disk on every render so that
+	// changes are picked up without restarting the process.
+	Development bool
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+}
+
+var _ Renderer = new(TemplateRenderer)
+
+// NewTemplateRenderer returns a TemplateRenderer for the templates within
+// dir, with the defaults set, and loads them.
+func NewTemplateRenderer(dir string, funcs template.FuncMap, development bool) (*TemplateRenderer, error) {
+	t := &TemplateRenderer{
+		Dir:         dir,
+		Extension:   defaultTemplateExtension,
+		LayoutDir:   defaultLayoutDir,
+		PartialDir:  defaultPartialDir,
+		Funcs:       funcs,
+		Development: development,
+	}
+
+	if err := t.Load(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Load (re)parses all templates from disk.
+func (t *TemplateRenderer) Load() error {
+	templates, err := t.parse()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.templates = templates
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Render executes the named template with data and writes the output to w.
+func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c *Context) error {
+	var (
+		templates map[string]*template.Template
+		err       error
+	)
+
+	if t.Development {
+		if templates, err = t.parse(); err != nil {
+			return err
+		}
+	} else {
+		t.mu.RLock()
+		templates = t.templates
+		t.mu.RUnlock()
+	}
+
+	tmpl, ok := templates[name]
+	if !ok {
+		return fmt.Errorf("lars => template '%s' not found", name)
+	}
+
+	if t.DefaultLayout != "" {
+		return tmpl.ExecuteTemplate(w, t.DefaultLayout, data)
+	}
+
+	return tmpl.Execute(w, data)
+}
+
+// parse walks Dir and returns a template set per page, each containing the
+// page along with all layouts and partials.
+func (t *TemplateRenderer) parse() (map[string]*template.Template, error) {
+	ext := t.Extension
+	if ext == "" {
+		ext = defaultTemplateExtension
+	}
+
+	layoutDir := t.LayoutDir
+	if layoutDir == "" {
+		layoutDir = defaultLayoutDir
+	}
+
+	partialDir := t.PartialDir
+	if partialDir == "" {
+		partialDir = defaultPartialDir
+	}
+
+	var shared, pages []string
+
+	err := filepath.Walk(t.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(path) != ext {
+			return nil
+		}
+
+		name := t.templateName(path, ext)
+
+		if strings.HasPrefix(name, layoutDir+"/") || strings.HasPrefix(name, partialDir+"/") {
+			shared = append(shared, path)
+		} else {
+			pages = append(pages, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base := template.New("").Funcs(t.Funcs)
+
+	for _, path := range shared {
+		if err = parseFile(base, t.templateName(path, ext), path); err != nil {
+			return nil, err
+		}
+	}
+
+	templates := make(map[string]*template.Template, len(pages))
+
+	for _, path := range pages {
+		tmpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		name := t.templateName(path, ext)
+
+		if err = parseFile(tmpl, name, path); err != nil {
+			return nil, err
+		}
+
+		templates[name] = tmpl.Lookup(name)
+	}
+
+	return templates, nil
+}
+
+func (t *TemplateRenderer) templateName(path, ext string) string {
+	rel, _ := filepath.Rel(t.Dir, path)
+	return filepath.ToSlash(strings.TrimSuffix(rel, ext))
+}
+
+func parseFile(tmpl *template.Template, name, path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpl.New(name).Parse(string(b))
+	return err
+}
@@ -0,0 +1,114 @@
+package lars
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/go-playground/assert.v1"
+)
+
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		Equal(t, os.MkdirAll(filepath.Dir(path), 0755), nil)
+		Equal(t, ioutil.WriteFile(path, []byte(contents), 0644), nil)
+	}
+}
+
+func TestTemplateRenderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lars-templates")
+	Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	writeTemplates(t, dir, map[string]string{
+		"layouts/main.html":  `<html>{{template "partials/nav" .}}{{block "content" .}}{{end}}</html>`,
+		"partials/nav.html":  `<nav>{{upper .Name}}</nav>`,
+		"users/show.html":    `{{template "layouts/main" .}}{{define "content"}}<p>{{.Name}}</p>{{end}}`,
+		"users/list.html":    `{{template "layouts/main" .}}{{define "content"}}<ul>{{.Name}}</ul>{{end}}`,
+		"ignored/readme.txt": `not a template`,
+	})
+
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+
+	tr, err := NewTemplateRenderer(dir, funcs, false)
+	Equal(t, err, nil)
+
+	l := New()
+	l.RegisterRenderer(tr)
+
+	l.Get("/users/show", func(c *Context) error {
+		return c.Render(http.StatusOK, "users/show", map[string]string{"Name": "<joey>"})
+	})
+	l.Get("/users/list", func(c *Context) error {
+		return c.Render(http.StatusOK, "users/list", map[string]string{"Name": "joey"})
+	})
+	l.Get("/missing", func(c *Context) error {
+		return c.Render(http.StatusOK, "missing", nil)
+	})
+
+	code, body := request(GET, "/users/show", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "<html><nav>&lt;JOEY&gt;</nav><p>&lt;joey&gt;</p></html>")
+
+	code, body = request(GET, "/users/list", l)
+	Equal(t, code, http.StatusOK)
+	Equal(t, body, "<html><nav>JOEY</nav><ul>joey</ul></html>")
+
+	code, _ = request(GET, "/missing", l)
+	Equal(t, code, http.StatusInternalServerError)
+
+	// templates are cached outside of development mode
+	writeTemplates(t, dir, map[string]string{
+		"partials/nav.html": `<nav>changed</nav>`,
+	})
+
+	_, body = request(GET, "/users/list", l)
+	Equal(t, body, "<html><nav>JOEY</nav><ul>joey</ul></html>")
+
+	tr.Development = true
+
+	_, body = request(GET, "/users/list", l)
+	Equal(t, body, "<html><nav>changed</nav><ul>joey</ul></html>")
+
+	// default layout
+	writeTemplates(t, dir, map[string]string{
+		"home.html": `{{define "content"}}home{{end}}`,
+	})
+
+	tr.DefaultLayout = "layouts/main"
+	l.Get("/", func(c *Context) error {
+		return c.Render(http.StatusOK, "home", nil)
+	})
+
+	_, body = request(GET, "/", l)
+	Equal(t, body, "<html><nav>changed</nav>home</html>")
+
+	// parse errors
+	writeTemplates(t, dir, map[string]string{
+		"broken.html": `{{.Name`,
+	})
+
+	code, _ = request(GET, "/", l)
+	Equal(t, code, http.StatusInternalServerError)
+
+	_, err = NewTemplateRenderer(dir, funcs, false)
+	NotEqual(t, err, nil)
+
+	_, err = NewTemplateRenderer(filepath.Join(dir, "does-not-exist"), nil, false)
+	NotEqual(t, err, nil)
+}
+
+func TestRenderWithoutRenderer(t *testing.T) {
+	l := New()
+	l.Get("/", func(c *Context) {
+		Equal(t, c.Render(http.StatusOK, "index", nil), ErrRendererNotRegistered)
+	})
+
+	code, _ := request(GET, "/", l)
+	Equal(t, code, http.StatusOK)
+}
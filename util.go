@@ -1,6 +1,11 @@
 package lars
 
-import "net/http"
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
 
 // wrapMiddleware wraps middleware.
 func wrapMiddleware(m Middleware) MiddlewareFunc {
@@ -13,6 +18,10 @@ func wrapMiddleware(m Middleware) MiddlewareFunc {
 		return wrapHandlerFuncMW(m)
 	case func(*Context):
 		return wrapHandlerFuncMW(m)
+	case ErrorReturningHandlerFunc:
+		return wrapErrorReturningHandlerFuncMW(m)
+	case func(*Context) error:
+		return wrapErrorReturningHandlerFuncMW(m)
 	case func(http.Handler) http.Handler:
 		return func(h HandlerFunc) HandlerFunc {
 			return func(c *Context) {
@@ -42,6 +51,23 @@ func wrapHandlerFuncMW(m HandlerFunc) MiddlewareFunc {
 	}
 }
 
+// wrapErrorReturningHandlerFuncMW wraps ErrorReturningHandlerFunc middleware,
+// the chain is halted when an error is returned.
+func wrapErrorReturningHandlerFuncMW(m ErrorReturningHandlerFunc) MiddlewareFunc {
+	return func(h HandlerFunc) HandlerFunc {
+		return func(c *Context) {
+			if err := m(c); err != nil {
+				c.err = err
+				return
+			}
+			if c.Response.status != http.StatusOK || c.Response.committed {
+				return
+			}
+			h(c)
+		}
+	}
+}
+
 // wrapHTTPHandlerFuncMW wraps http.HandlerFunc middleware.
 func wrapHTTPHandlerFuncMW(m http.HandlerFunc) MiddlewareFunc {
 	return func(h HandlerFunc) HandlerFunc {
@@ -61,6 +87,10 @@ func wrapHandler(h Handler) HandlerFunc {
 		return h
 	case func(*Context):
 		return h
+	case ErrorReturningHandlerFunc:
+		return wrapErrorReturningHandler(h)
+	case func(*Context) error:
+		return wrapErrorReturningHandler(h)
 	case http.Handler, http.HandlerFunc:
 		return func(c *Context) {
 			h.(http.Handler).ServeHTTP(c.Response, c.Request)
@@ -73,3 +103,65 @@ func wrapHandler(h Handler) HandlerFunc {
 		panic("unknown handler")
 	}
 }
+
+// wrapErrorReturningHandler wraps ErrorReturningHandlerFunc handlers, storing
+// any returned error on the Context for middleware and the error handler.
+func wrapErrorReturningHandler(h ErrorReturningHandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		if err := h(c); err != nil {
+			c.err = err
+		}
+	}
+}
+
+// validMethod reports whether method is a valid HTTP method token as defined
+// by RFC 7230 section 3.2.6, other than the "*" reserved for Any.
+func validMethod(method string) bool {
+	if method == "" || method == methodAny {
+		return false
+	}
+	for i := 0; i < len(method); i++ {
+		c := method[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validCallback reports whether callback is safe to use as a JSONP callback;
+// one or more JavaScript identifiers separated by dots.
+func validCallback(callback string) bool {
+	if callback == "" {
+		return false
+	}
+	for _, id := range strings.Split(callback, ".") {
+		if id == "" {
+			return false
+		}
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$' || i > 0 && '0' <= c && c <= '9' {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// handlerName returns the name of the handler's function, or its type when
+// the handler is not a function.
+func handlerName(h Handler) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() == reflect.Func {
+		return runtime.FuncForPC(v.Pointer()).Name()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	return v.Type().String()
+}